@@ -0,0 +1,179 @@
+package avro
+
+import (
+	"math"
+	"sync"
+)
+
+// AppendEncoder implements Encoder for the binary encoding by appending to
+// a byte slice owned by the caller, which saves the allocations and
+// io.Writer calls of an Encoder writing to an io.Writer. Once the slice has
+// grown large enough for the records written, encoding records of
+// primitive fields doesn't allocate at all.
+//
+//	enc := avro.NewAppendEncoder(make([]byte, 0, 1024))
+//	for _, record := range records {
+//		enc.Reset()
+//		if err := writer.Write(record, enc); err != nil {
+//			return err
+//		}
+//		send(enc.Bytes())
+//	}
+type AppendEncoder struct {
+	buf []byte
+}
+
+// NewAppendEncoder creates an AppendEncoder which appends to buf.
+func NewAppendEncoder(buf []byte) *AppendEncoder {
+	return &AppendEncoder{buf: buf}
+}
+
+// Bytes returns the slice appended to, which is only valid until the next write or Reset.
+func (e *AppendEncoder) Bytes() []byte {
+	return e.buf
+}
+
+// Reset empties the slice appended to, keeping its capacity for reuse.
+func (e *AppendEncoder) Reset() {
+	e.buf = e.buf[:0]
+}
+
+// WriteNull writes a null value. Doesn't actually do anything in this implementation.
+func (e *AppendEncoder) WriteNull(_ interface{}) {
+	//do nothing
+}
+
+// WriteBoolean writes a boolean value.
+func (e *AppendEncoder) WriteBoolean(x bool) {
+	if x {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// WriteInt writes an int value.
+func (e *AppendEncoder) WriteInt(x int32) {
+	e.buf = appendVarint32(e.buf, x)
+}
+
+// WriteLong writes a long value.
+func (e *AppendEncoder) WriteLong(x int64) {
+	e.buf = appendVarint64(e.buf, x)
+}
+
+// WriteFloat writes a float value.
+func (e *AppendEncoder) WriteFloat(x float32) {
+	e.buf = appendFloat32(e.buf, x)
+}
+
+// WriteDouble writes a double value.
+func (e *AppendEncoder) WriteDouble(x float64) {
+	e.buf = appendFloat64(e.buf, x)
+}
+
+// WriteBytes writes a bytes value.
+func (e *AppendEncoder) WriteBytes(x []byte) {
+	e.buf = appendVarint64(e.buf, int64(len(x)))
+	e.buf = append(e.buf, x...)
+}
+
+// WriteString writes a string value.
+func (e *AppendEncoder) WriteString(x string) {
+	e.buf = appendVarint64(e.buf, int64(len(x)))
+	e.buf = append(e.buf, x...)
+}
+
+// WriteArrayStart should be called when starting to serialize an array providing it with a number of items in
+// array block.
+func (e *AppendEncoder) WriteArrayStart(count int64) {
+	e.buf = appendVarint64(e.buf, count)
+}
+
+// WriteArrayNext should be called after finishing writing an array block either passing it the number of items in
+// next block or 0 indicating the end of array.
+func (e *AppendEncoder) WriteArrayNext(count int64) {
+	e.buf = appendVarint64(e.buf, count)
+}
+
+// WriteMapStart should be called when starting to serialize a map providing it with a number of items in
+// map block.
+func (e *AppendEncoder) WriteMapStart(count int64) {
+	e.buf = appendVarint64(e.buf, count)
+}
+
+// WriteMapNext should be called after finishing writing a map block either passing it the number of items in
+// next block or 0 indicating the end of map.
+func (e *AppendEncoder) WriteMapNext(count int64) {
+	e.buf = appendVarint64(e.buf, count)
+}
+
+// WriteRaw writes raw bytes to this Encoder.
+func (e *AppendEncoder) WriteRaw(x []byte) {
+	e.buf = append(e.buf, x...)
+}
+
+type appendMarshaler struct {
+	enc    AppendEncoder
+	writer anyDatumWriter
+}
+
+var appendMarshalers = sync.Pool{New: func() interface{} { return &appendMarshaler{} }}
+
+// AppendMarshal appends the binary encoding of v, a *GenericRecord or a
+// struct as accepted by NewDatumWriter, to buf and returns the extended
+// slice. Encoding a record of primitive fields doesn't allocate if buf has
+// enough capacity.
+func AppendMarshal(buf []byte, schema Schema, v interface{}) ([]byte, error) {
+	if schema == nil {
+		return buf, ErrSchemaNotSet
+	}
+	m := appendMarshalers.Get().(*appendMarshaler)
+	m.enc.buf = buf
+	m.writer.sdr.schema, m.writer.gdr.schema = schema, schema
+	err := m.writer.Write(v, &m.enc)
+	buf = m.enc.buf
+	*m = appendMarshaler{}
+	appendMarshalers.Put(m)
+	return buf, err
+}
+
+// Marshal returns the binary encoding of v, see AppendMarshal.
+func Marshal(schema Schema, v interface{}) ([]byte, error) {
+	return AppendMarshal(nil, schema, v)
+}
+
+func appendVarint32(buf []byte, n int32) []byte {
+	ux := uint32(n) << 1
+	if n < 0 {
+		ux = ^ux
+	}
+	for ux >= 0x80 {
+		buf = append(buf, byte(ux)|0x80)
+		ux >>= 7
+	}
+	return append(buf, byte(ux))
+}
+
+func appendVarint64(buf []byte, n int64) []byte {
+	ux := uint64(n) << 1
+	if n < 0 {
+		ux = ^ux
+	}
+	for ux >= 0x80 {
+		buf = append(buf, byte(ux)|0x80)
+		ux >>= 7
+	}
+	return append(buf, byte(ux))
+}
+
+func appendFloat32(buf []byte, x float32) []byte {
+	bits := math.Float32bits(x)
+	return append(buf, byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24))
+}
+
+func appendFloat64(buf []byte, x float64) []byte {
+	bits := math.Float64bits(x)
+	return append(buf, byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24),
+		byte(bits>>32), byte(bits>>40), byte(bits>>48), byte(bits>>56))
+}
@@ -0,0 +1,138 @@
+package avro
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestAppendEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoders := []Encoder{NewBinaryEncoder(buf), NewAppendEncoder(make([]byte, 0, 1))}
+	for _, enc := range encoders {
+		enc.WriteBoolean(true)
+		enc.WriteBoolean(false)
+		for _, x := range []int32{0, -1, 1, 63, -64, 64, math.MaxInt32, math.MinInt32} {
+			enc.WriteInt(x)
+		}
+		for _, x := range []int64{0, -1, 1, math.MaxInt64, math.MinInt64} {
+			enc.WriteLong(x)
+		}
+		enc.WriteFloat(-1.5)
+		enc.WriteDouble(math.Pi)
+		enc.WriteBytes([]byte{1, 2, 3})
+		enc.WriteString("string")
+		enc.WriteArrayStart(2)
+		enc.WriteArrayNext(0)
+		enc.WriteMapStart(3)
+		enc.WriteMapNext(0)
+		enc.WriteRaw([]byte("raw"))
+	}
+	enc := encoders[1].(*AppendEncoder)
+	assert(t, enc.Bytes(), buf.Bytes())
+	enc.Reset()
+	assert(t, len(enc.Bytes()), 0)
+	assert(t, cap(enc.Bytes()) >= buf.Len(), true)
+}
+
+func TestAppendMarshal(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	in := randomPrimitiveObject()
+	buf := &bytes.Buffer{}
+	assert(t, NewDatumWriter(schema).Write(in, NewBinaryEncoder(buf)), nil)
+
+	encoded, err := AppendMarshal([]byte("prefix"), schema, in)
+	assert(t, err, nil)
+	assert(t, encoded, append([]byte("prefix"), buf.Bytes()...))
+	encoded, err = Marshal(schema, in)
+	assert(t, err, nil)
+	assert(t, encoded, buf.Bytes())
+
+	record := randomPrimitiveRecord(schema)
+	encoded = make([]byte, 0, 1024)
+	for _, v := range []interface{}{in, record} {
+		allocs := testing.AllocsPerRun(100, func() {
+			if encoded, err = AppendMarshal(encoded[:0], schema, v); err != nil {
+				t.Fatal(err)
+			}
+		})
+		assert(t, allocs, float64(0))
+	}
+
+	_, err = AppendMarshal(nil, nil, record)
+	assert(t, err, ErrSchemaNotSet)
+}
+
+func randomPrimitiveRecord(schema Schema) *GenericRecord {
+	p := randomPrimitiveObject()
+	record := NewGenericRecord(schema)
+	record.Set("booleanField", p.BooleanField)
+	record.Set("intField", p.IntField)
+	record.Set("longField", p.LongField)
+	record.Set("floatField", p.FloatField)
+	record.Set("doubleField", p.DoubleField)
+	record.Set("bytesField", p.BytesField)
+	record.Set("stringField", p.StringField)
+	return record
+}
+
+func BenchmarkGenericDatumWriter_binaryEncoder(b *testing.B) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	record := randomPrimitiveRecord(schema)
+	writer := NewGenericDatumWriter().SetSchema(schema)
+	buf := &bytes.Buffer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_ = writer.Write(record, NewBinaryEncoder(buf))
+	}
+}
+
+func BenchmarkGenericDatumWriter_appendEncoder(b *testing.B) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	record := randomPrimitiveRecord(schema)
+	writer := NewGenericDatumWriter().SetSchema(schema)
+	enc := NewAppendEncoder(nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc.Reset()
+		_ = writer.Write(record, enc)
+	}
+}
+
+func BenchmarkAppendMarshal_generic(b *testing.B) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	record := randomPrimitiveRecord(schema)
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendMarshal(buf[:0], schema, record)
+	}
+}
+
+func BenchmarkAppendMarshal_specific(b *testing.B) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	in := randomPrimitiveObject()
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendMarshal(buf[:0], schema, in)
+	}
+}
+
+func BenchmarkSpecificDatumWriter_binaryEncoder(b *testing.B) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	in := randomPrimitiveObject()
+	writer := NewSpecificDatumWriter().SetSchema(schema)
+	buf := &bytes.Buffer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_ = writer.Write(in, NewBinaryEncoder(buf))
+	}
+}
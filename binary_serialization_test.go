@@ -2,6 +2,7 @@ package avro
 
 import (
 	"bytes"
+	"errors"
 	"math/rand"
 	"testing"
 )
@@ -39,6 +40,30 @@ func TestBooleanSerialization(t *testing.T) {
 	}
 }
 
+func TestBufferedBinaryEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewBufferedBinaryEncoder(buf)
+	enc.WriteString("buffered")
+	assert(t, buf.Len(), 0)
+	assert(t, enc.Flush(), nil)
+	s, err := NewBinaryDecoder(buf.Bytes()).ReadString()
+	assert(t, err, nil)
+	assert(t, s, "buffered")
+
+	schema := MustParseSchema(primitiveSchemaRaw)
+	output := &failingWriter{limit: 10, err: errors.New("disk full")}
+	enc = NewBufferedBinaryEncoder(output)
+	assert(t, NewDatumWriter(schema).Write(&primitive{StringField: "fits in the buffer"}, enc), nil)
+	assert(t, enc.Flush(), output.err)
+	assert(t, enc.Err(), output.err)
+	assert(t, NewDatumWriter(schema).Write(&primitive{}, enc), output.err)
+
+	// unbuffered encoders report errors as soon as they happen
+	output = &failingWriter{limit: 10, err: errors.New("disk full")}
+	err = NewDatumWriter(schema).Write(randomPrimitiveObject(), NewBinaryEncoder(output))
+	assert(t, err, output.err)
+}
+
 func BenchmarkBooleanSerialization(b *testing.B) {
 	buf := &bytes.Buffer{}
 	enc := NewBinaryEncoder(buf)
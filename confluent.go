@@ -0,0 +1,155 @@
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// Size of the header of a Confluent wire format message: a zero magic byte
+// followed by the schema registry ID of the writer schema.
+const confluentHeaderSize = 5
+
+// ConfluentSerializer encodes datums in the Confluent wire format used by
+// Kafka clients with a schema registry: a zero magic byte, the ID of the
+// writer schema in the registry as a 4-byte big-endian integer and the
+// binary encoding of the datum. It's safe for concurrent use if its
+// DatumWriter is.
+type ConfluentSerializer struct {
+	registry    SchemaRegistry
+	subject     string
+	schema      Schema
+	datumWriter DatumWriter
+
+	mu     sync.RWMutex
+	header []byte
+}
+
+// NewConfluentSerializer creates a ConfluentSerializer writing datums of the
+// given schema with datumWriter, e.g. NewDatumWriter(schema). The schema is
+// registered under subject, e.g. "<topic>-value", when the first datum is
+// serialized.
+func NewConfluentSerializer(registry SchemaRegistry, subject string, schema Schema, datumWriter DatumWriter) (*ConfluentSerializer, error) {
+	if schema == nil {
+		return nil, ErrSchemaNotSet
+	}
+	return &ConfluentSerializer{
+		registry:    registry,
+		subject:     subject,
+		schema:      schema,
+		datumWriter: datumWriter,
+	}, nil
+}
+
+// Serialize returns the message of a single datum.
+func (s *ConfluentSerializer) Serialize(v interface{}) ([]byte, error) {
+	return s.AppendSerialize(nil, v)
+}
+
+// AppendSerialize appends the message of a single datum to buf and returns
+// the extended slice.
+func (s *ConfluentSerializer) AppendSerialize(buf []byte, v interface{}) ([]byte, error) {
+	header, err := s.registeredHeader()
+	if err != nil {
+		return buf, err
+	}
+	enc := NewAppendEncoder(append(buf, header...))
+	if err := s.datumWriter.Write(v, enc); err != nil {
+		return buf, err
+	}
+	return enc.Bytes(), nil
+}
+
+// registeredHeader returns the header of the messages, registering the
+// schema on first use.
+func (s *ConfluentSerializer) registeredHeader() ([]byte, error) {
+	s.mu.RLock()
+	header := s.header
+	s.mu.RUnlock()
+	if header != nil {
+		return header, nil
+	}
+
+	id, err := s.registry.Register(s.subject, s.schema)
+	if err != nil {
+		return nil, fmt.Errorf("ConfluentSerializer: Cannot register schema under subject %s: %s", s.subject, err.Error())
+	}
+	header = make([]byte, confluentHeaderSize)
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	s.mu.Lock()
+	s.header = header
+	s.mu.Unlock()
+	return header, nil
+}
+
+// ConfluentSchemaID returns the schema registry ID of the writer schema of a
+// Confluent wire format message.
+func ConfluentSchemaID(message []byte) (int, error) {
+	if len(message) < confluentHeaderSize || message[0] != 0 {
+		return 0, ErrNotConfluentMessage
+	}
+	return int(binary.BigEndian.Uint32(message[1:confluentHeaderSize])), nil
+}
+
+// ConfluentDeserializer decodes Confluent wire format messages, see
+// ConfluentSerializer. The writer schema of every message is looked up in a
+// SchemaRegistry by its ID and resolved to the reader schema with a
+// DatumProjector, which is built only once per writer schema. It's safe for
+// concurrent use.
+type ConfluentDeserializer struct {
+	readerSchema Schema
+	registry     SchemaRegistry
+
+	mu      sync.RWMutex
+	readers map[int]DatumReader
+}
+
+// NewConfluentDeserializer creates a ConfluentDeserializer which reads
+// messages as readerSchema. If readerSchema is nil, every message is read as
+// its writer schema.
+func NewConfluentDeserializer(readerSchema Schema, registry SchemaRegistry) *ConfluentDeserializer {
+	return &ConfluentDeserializer{
+		readerSchema: readerSchema,
+		registry:     registry,
+		readers:      make(map[int]DatumReader),
+	}
+}
+
+// Deserialize reads the datum of a message into v, a pointer as accepted by DatumReader.Read.
+func (d *ConfluentDeserializer) Deserialize(message []byte, v interface{}) error {
+	id, err := ConfluentSchemaID(message)
+	if err != nil {
+		return err
+	}
+	reader, err := d.reader(id)
+	if err != nil {
+		return err
+	}
+	return reader.Read(v, NewBinaryDecoder(message[confluentHeaderSize:]))
+}
+
+// reader returns the DatumReader of a writer schema, building it on first use.
+func (d *ConfluentDeserializer) reader(id int) (DatumReader, error) {
+	d.mu.RLock()
+	reader, ok := d.readers[id]
+	d.mu.RUnlock()
+	if ok {
+		return reader, nil
+	}
+
+	schema, err := d.registry.GetSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	if d.readerSchema != nil {
+		if reader, err = NewDatumProjector(d.readerSchema, schema); err != nil {
+			return nil, fmt.Errorf("ConfluentDeserializer: Cannot resolve writer schema %d to reader schema: %s", id, err.Error())
+		}
+	} else {
+		reader = NewDatumReader(schema)
+	}
+	d.mu.Lock()
+	d.readers[id] = reader
+	d.mu.Unlock()
+	return reader, nil
+}
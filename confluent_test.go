@@ -0,0 +1,78 @@
+package avro
+
+import (
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfluentSerde(t *testing.T) {
+	writerSchema := MustParseSchema(singleObjectWriterSchemaRaw)
+	readerSchema := MustParseSchema(singleObjectReaderSchemaRaw)
+	server := httptest.NewServer(NewMemorySchemaRegistry())
+	defer server.Close()
+	registry := NewCachedSchemaRegistry(NewSchemaRegistryClient(server.URL, nil))
+
+	// take up ID 1 so that the writer schema doesn't get it by chance
+	if _, err := registry.Register("other-value", readerSchema); err != nil {
+		t.Fatal(err)
+	}
+	serializer, err := NewConfluentSerializer(registry, "users-value", writerSchema, NewDatumWriter(writerSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := serializer.Serialize(&singleObjectUser{Name: "Ann", Age: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, message[0], byte(0))
+	assert(t, binary.BigEndian.Uint32(message[1:5]), uint32(2))
+	id, err := ConfluentSchemaID(message)
+	assert(t, err, nil)
+	assert(t, id, 2)
+
+	// resolved to the reader schema
+	deserializer := NewConfluentDeserializer(readerSchema, registry)
+	for i := 0; i < 2; i++ {
+		var user singleObjectUser
+		assert(t, deserializer.Deserialize(message, &user), nil)
+		assert(t, user, singleObjectUser{Name: "Ann", Email: "unknown"})
+	}
+	var record *GenericRecord
+	assert(t, deserializer.Deserialize(message, &record), nil)
+	assert(t, record.Get("name"), "Ann")
+	assert(t, record.Get("email"), "unknown")
+
+	// read as the writer schema fetched from the registry
+	var user singleObjectUser
+	uncached := NewSchemaRegistryClient(server.URL, nil)
+	assert(t, NewConfluentDeserializer(nil, uncached).Deserialize(message, &user), nil)
+	assert(t, user, singleObjectUser{Name: "Ann", Age: 42})
+
+	// messages are appended to a buffer
+	buf, err := serializer.AppendSerialize([]byte("key"), &singleObjectUser{Name: "Ann", Age: 42})
+	assert(t, err, nil)
+	assert(t, buf, append([]byte("key"), message...))
+}
+
+func TestConfluentDeserializer_errors(t *testing.T) {
+	schema := MustParseSchema(singleObjectWriterSchemaRaw)
+	registry := NewMemorySchemaRegistry()
+	deserializer := NewConfluentDeserializer(schema, registry)
+	message := []byte{0, 0, 0, 0, 1, 6, 'A', 'n', 'n', 84}
+
+	var user singleObjectUser
+	assert(t, deserializer.Deserialize(message, &user), ErrSchemaNotFound)
+	assert(t, deserializer.Deserialize(message[:4], &user), ErrNotConfluentMessage)
+	assert(t, deserializer.Deserialize(append([]byte{1}, message[1:]...), &user), ErrNotConfluentMessage)
+
+	registry.Register("users-value", schema)
+	assert(t, deserializer.Deserialize(message, &user), nil)
+	assert(t, user, singleObjectUser{Name: "Ann", Age: 42})
+	assert(t, deserializer.Deserialize(message[:len(message)-1], &user), ErrUnexpectedEOF)
+
+	incompatible := MustParseSchema(`{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}]}`)
+	if err := NewConfluentDeserializer(incompatible, registry).Deserialize(message, &user); err == nil {
+		t.Fatal("Expected an error resolving an incompatible writer schema")
+	}
+}
@@ -3,13 +3,16 @@ package avro
 import (
 	"bufio"
 	"bytes"
-	"compress/flate"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Support decoding the avro Object Container File format.
@@ -31,6 +34,9 @@ const (
 
 	schemaKey = "avro.schema"
 	codecKey  = "avro.codec"
+
+	// metadata keys starting with this prefix are reserved for the avro implementation
+	reservedMetaPrefix = "avro."
 )
 
 var magic = []byte{'O', 'b', 'j', containerMagicVersion}
@@ -38,20 +44,128 @@ var magic = []byte{'O', 'b', 'j', containerMagicVersion}
 // DataFileReader is a reader for Avro Object Container Files.
 // More here: https://avro.apache.org/docs/current/spec.html#Object+Container+Files
 type DataFileReader struct {
-	r             io.Reader
-	sharedCopyBuf []byte
-	header        *objFileHeader
-	block         *DataBlock
-	dec           Decoder
-	datum         DatumReader
-	codec         fileCodec
-	err           error
+	r       io.Reader
+	seeker  io.Seeker // nil unless the input supports seeking
+	closer  io.Closer // nil unless the input needs closing
+	in      *positionReader
+	syncBuf [containerSyncSize]byte
+	header  *objFileHeader
+	schema  Schema
+	block   *DataBlock
+	dec     Decoder
+	datum   DatumReader
+	codec   Codec
+	err     error
+
+	// the current block as stored in the input, it is only decompressed
+	// once records are read from it
+	raw         rawBlock
+	rawConsumed bool // raw was returned by NextRawBlock
+
+	// offsets of the current block, see rawBlock
+	blockStart, blockEnd int64
+
+	// blocks are decompressed in the background if readAhead is set
+	readAhead int
+	ahead     *blockPipeline
+
+	readerSchema Schema
+	limits       DecoderLimits
+
+	// offset of the first block and the index used by SeekRecord
+	dataStart int64
+	index     *BlockIndex
+
+	// concatenated files are expected if set
+	concatenated  bool
+	schemaChanged bool
+
+	// damaged data is skipped if skipCorrupted is set
+	skipCorrupted bool
+	onSkip        func(SkippedRange)
+	skipped       SkipStats
+
+	// block buffers are reused between blocks
+	compressed []byte
+	data       []byte
+}
+
+// DataFileReaderOptions configures a DataFileReader.
+// The zero value is ready to use.
+type DataFileReaderOptions struct {
+	// BufferSize is the size of the read buffer placed in front of the
+	// input, defaults to 64KiB.
+	BufferSize int
+
+	// ReadAhead is the number of blocks read ahead of the one being
+	// consumed. If set, blocks are read from the input and decompressed by
+	// this many background goroutines while records are decoded on the
+	// calling goroutine in their original order. The background work stops
+	// at the first error, on Close and when seeking. If zero, every block is
+	// read and decompressed by the calling goroutine.
+	ReadAhead int
+
+	// SkipCorrupted makes the reader carry on after damaged data instead of
+	// stopping with an error. A block which can't be decompressed or decoded
+	// is skipped as a whole. If a block can't even be read, e.g. because it
+	// is truncated or not followed by the sync marker, the input is scanned
+	// for the next sync marker after the start of the block and reading
+	// resumes from there. Scanning starts from the position the failure was
+	// detected at instead if the input does not support seeking, which may
+	// skip more data. See OnSkip and DataFileReader.Skipped.
+	SkipCorrupted bool
+
+	// OnSkip, if set, is called with every range of the input which was
+	// skipped because of SkipCorrupted.
+	OnSkip func(SkippedRange)
+
+	// Concatenated allows reading several container files concatenated
+	// into one input, e.g. with "cat a.avro b.avro". When a new file header
+	// follows a block, the reader switches to the schema, codec and sync
+	// marker of the new file and the header accessors describe it from then
+	// on, see also DataFileReader.SchemaChanged. Seeking only works within
+	// the file currently being read.
+	Concatenated bool
+
+	// Index is a BlockIndex of the file for SeekRecord, see ReadBlockIndex.
+	Index *BlockIndex
+
+	// ReaderSchema is the schema records are read as. If set, every record
+	// is resolved from the writer schema of the file to this schema with a
+	// DatumProjector, which allows reading files written with older or
+	// newer versions of a schema. If nil, the writer schema is used as is.
+	ReaderSchema Schema
+
+	// Limits bounds the resources used to read the header, every block and
+	// every record, for files from untrusted sources. A block larger than
+	// Limits.MaxBlockSize can't be read, which SkipCorrupted skips.
+	Limits DecoderLimits
+}
+
+const defaultReadBufferSize = 64 * 1024
+
+// positionReader is a buffered reader which keeps track of the offset of
+// the next byte it will return from the underlying input.
+type positionReader struct {
+	*bufio.Reader
+	pos int64
+}
+
+func (r *positionReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.pos += int64(n)
+	return
+}
+
+func (r *positionReader) Discard(n int) (discarded int, err error) {
+	discarded, err = r.Reader.Discard(n)
+	r.pos += int64(discarded)
+	return
 }
 
-var codecs = map[string]fileCodec{
-	"":        nullCodec{},
-	"null":    nullCodec{},
-	"deflate": flateCodec{},
+func (r *positionReader) Reset(input io.Reader, pos int64) {
+	r.Reader.Reset(input)
+	r.pos = pos
 }
 
 // The header for object container files
@@ -68,71 +182,219 @@ func readObjFileHeader(dec Decoder) (*objFileHeader, error) {
 	return header, err
 }
 
-// NewDataFileReader enables reading an object container file from the filesystem.
-// May return an error if the file contains invalid data or is just missing.
-//
-// The second DatumReader argument is deprecated, only there for source compatibility.
-// Will be removed in an upcoming compatibility break.
-func NewDataFileReader(filename string, ignoreMe ...DatumReader) (*DataFileReader, error) {
-	if len(ignoreMe) > 1 {
-		return nil, errors.New("Not supported sending multiple readers")
-	} else if len(ignoreMe) == 1 {
-		switch ignoreMe[0].(type) {
-		case *GenericDatumReader, *SpecificDatumReader, *anyDatumReader:
-			// nothing
-			break
-		default:
-			return nil, fmt.Errorf("Datum reader input deprecated, don't know what to do with %#v", ignoreMe[0])
+// writeObjFileHeader encodes the header by hand rather than through a
+// DatumWriter so that the metadata entries are written in a stable order.
+func writeObjFileHeader(enc Encoder, header *objFileHeader) {
+	keys := make([]string, 0, len(header.Meta))
+	for key := range header.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	enc.WriteRaw(header.Magic)
+	if len(keys) > 0 {
+		enc.WriteMapStart(int64(len(keys)))
+		for _, key := range keys {
+			enc.WriteString(key)
+			enc.WriteBytes(header.Meta[key])
 		}
 	}
+	enc.WriteMapNext(0)
+	enc.WriteRaw(header.Sync)
+}
+
+// NewDataFileReader enables reading an object container file from the filesystem.
+// May return an error if the file contains invalid data or is just missing.
+func NewDataFileReader(filename string) (*DataFileReader, error) {
+	return NewDataFileReaderWithOptions(filename, DataFileReaderOptions{})
+}
+
+// NewDataFileReaderWithOptions is like NewDataFileReader but allows the
+// reader to be configured.
+func NewDataFileReaderWithOptions(filename string, options DataFileReaderOptions) (*DataFileReader, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	reader, err := newDataFileReader(f)
+	reader, err := newDataFileReader(f, f, options)
 	if err != nil {
 		// If there's any decoding issues, try not leaking a file handle.
 		f.Close()
 	}
 	return reader, err
+}
+
+// NewDataFileStreamReader reads an object container file from any io.Reader,
+// e.g. an HTTP response body or an entry in a tar archive. The input is
+// read sequentially so features that require seeking are not available.
+//
+// Close will close the input if it implements io.Closer.
+func NewDataFileStreamReader(input io.Reader, options DataFileReaderOptions) (*DataFileReader, error) {
+	return newDataFileReader(input, nil, options)
+}
 
+// NewDataFileSeekReader reads an object container file from an io.ReadSeeker
+// starting at its current position. Unlike NewDataFileStreamReader, the
+// reader is able to seek within the input.
+//
+// Close will close the input if it implements io.Closer.
+func NewDataFileSeekReader(input io.ReadSeeker, options DataFileReaderOptions) (*DataFileReader, error) {
+	return newDataFileReader(input, input, options)
 }
 
-func newDataFileReader(input io.Reader) (reader *DataFileReader, err error) {
-	dec := NewBinaryDecoderReader(input) // Since dec doesn't buffer, we can share it.
+// NewDataFileReaderAt reads an object container file of the given size from
+// an io.ReaderAt, e.g. an in-memory buffer or a remote object store client.
+// The reader is able to seek within the input.
+//
+// Close will close the input if it implements io.Closer.
+func NewDataFileReaderAt(input io.ReaderAt, size int64, options DataFileReaderOptions) (*DataFileReader, error) {
+	section := io.NewSectionReader(input, 0, size)
+	reader, err := newDataFileReader(section, section, options)
+	if reader != nil {
+		reader.closer, _ = input.(io.Closer)
+	}
+	return reader, err
+}
+
+func newDataFileReader(input io.Reader, seeker io.Seeker, options DataFileReaderOptions) (reader *DataFileReader, err error) {
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultReadBufferSize
+	}
+	var start int64
+	if seeker != nil {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	in := &positionReader{Reader: bufio.NewReaderSize(input, bufferSize), pos: start}
+	dec := NewBinaryDecoderReaderWithLimits(in, options.Limits) // Since dec doesn't buffer, we can share it.
 	reader = &DataFileReader{
-		sharedCopyBuf: make([]byte, 4096),
-		r:             input,
-		dec:           dec,
+		r:      input,
+		seeker: seeker,
+		in:     in,
+		dec:    dec,
+
+		readerSchema:  options.ReaderSchema,
+		limits:        options.Limits,
+		readAhead:     options.ReadAhead,
+		skipCorrupted: options.SkipCorrupted,
+		onSkip:        options.OnSkip,
+		concatenated:  options.Concatenated,
 	}
+	reader.closer, _ = input.(io.Closer)
 
-	if reader.header, err = readObjFileHeader(dec); err != nil {
-		return nil, fmt.Errorf("DataFileReader: Error reading header: %s", err.Error())
+	if err = reader.readHeader(); err != nil {
+		return nil, err
+	}
+	reader.dataStart = in.pos
+	if options.Index != nil {
+		if err = reader.checkIndex(options.Index); err != nil {
+			return nil, err
+		}
 	}
 
-	if !bytes.Equal(reader.header.Magic, magic) {
-		return nil, ErrNotAvroFile // TODO: consider formatting error magic value in
+	// A file without any blocks is valid, it just has nothing to read.
+	if err := reader.NextBlock(); err != nil && err != io.EOF {
+		return nil, err
 	}
 
-	schema, err := ParseSchema(string(reader.header.Meta[schemaKey]))
+	return reader, nil
+}
+
+// readHeader reads a file header from the input and sets the reader up for
+// the blocks which follow it.
+func (reader *DataFileReader) readHeader() error {
+	header, err := readObjFileHeader(reader.dec)
 	if err != nil {
-		return nil, err
+		if _, ok := err.(*LimitError); ok {
+			return err
+		}
+		return fmt.Errorf("DataFileReader: Error reading header: %s", err.Error())
 	}
-	reader.datum = NewDatumReader(schema)
 
-	codecName := string(reader.header.Meta[codecKey])
-	if codec := codecs[codecName]; codec == nil {
-		return nil, fmt.Errorf("DataFileReader: Don't know how to decode codec %s", codecName)
-	} else {
-		reader.codec = codec
+	if !bytes.Equal(header.Magic, magic) {
+		return ErrNotAvroFile // TODO: consider formatting error magic value in
 	}
 
-	if err := reader.NextBlock(); err != nil {
-		return nil, err
+	schema, err := ParseSchema(string(header.Meta[schemaKey]))
+	if err != nil {
+		return err
 	}
 
-	return reader, nil
+	codecName := string(header.Meta[codecKey])
+	codec := lookupCodec(codecName)
+	if codec == nil {
+		return fmt.Errorf("DataFileReader: Don't know how to decode codec %s", codecName)
+	}
+
+	// the datum reader is only rebuilt if a concatenated file has a new schema
+	if same, _ := sameSchema(schema, reader.schema); !same {
+		var datum DatumReader
+		if reader.readerSchema != nil {
+			// the projection plan is built only once for the whole file
+			if datum, err = NewDatumProjector(reader.readerSchema, schema); err != nil {
+				return fmt.Errorf("DataFileReader: Cannot resolve writer schema to reader schema: %s", err.Error())
+			}
+		} else {
+			datum = NewDatumReader(schema)
+		}
+		reader.schemaChanged = reader.schema != nil
+		reader.schema, reader.datum = schema, datum
+	}
+	reader.header, reader.codec = header, codec
+	return nil
+}
+
+// SchemaChanged tells if the reader has moved on to a concatenated file
+// with a different writer schema since the reader was created or
+// SchemaChanged was last called. This is only possible when reading with
+// DataFileReaderOptions.Concatenated.
+func (reader *DataFileReader) SchemaChanged() bool {
+	changed := reader.schemaChanged
+	reader.schemaChanged = false
+	return changed
+}
+
+// Schema returns the writer schema embedded in the file header,
+// regardless of any DataFileReaderOptions.ReaderSchema.
+func (reader *DataFileReader) Schema() Schema {
+	return reader.schema
+}
+
+// Codec returns the name of the codec the data blocks are compressed with.
+func (reader *DataFileReader) Codec() string {
+	if name := string(reader.header.Meta[codecKey]); name != "" {
+		return name
+	}
+	return codecNull
+}
+
+// SyncMarker returns a copy of the 16-byte marker which follows every block.
+func (reader *DataFileReader) SyncMarker() []byte {
+	return append([]byte(nil), reader.header.Sync...)
+}
+
+// Meta returns a copy of the value of a header metadata entry and a bool
+// representing if it exists. This includes the reserved "avro.schema" and
+// "avro.codec" entries.
+func (reader *DataFileReader) Meta(key string) ([]byte, bool) {
+	value, ok := reader.header.Meta[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), value...), true
+}
+
+// MetaKeys returns the sorted keys of all header metadata entries.
+func (reader *DataFileReader) MetaKeys() []string {
+	keys := make([]string, 0, len(reader.header.Meta))
+	for key := range reader.header.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (reader *DataFileReader) stop(err error) error {
@@ -168,14 +430,28 @@ func (reader *DataFileReader) HasNext() bool {
 }
 
 func (reader *DataFileReader) advance() bool {
-	if reader.block == nil {
-		return false
-	} else if reader.block.BlockRemaining == 0 {
-		if err := reader.NextBlock(); err != nil {
-			return false
+	for reader.block != nil {
+		// Empty blocks are valid, so keep going until there is something to read.
+		if reader.block.BlockRemaining == 0 {
+			if err := reader.NextBlock(); err != nil {
+				return false
+			}
+			continue
+		}
+		if reader.block.decoder == nil {
+			if err := reader.decompressBlock(); err != nil {
+				if reader.skipCorrupted {
+					reader.skipBlock(err)
+					continue
+				}
+				reader.block = nil
+				reader.stop(err)
+				return false
+			}
 		}
+		return true
 	}
-	return true
+	return false
 }
 
 // Next reads the next value from file and fills the given value with data.
@@ -186,16 +462,19 @@ func (reader *DataFileReader) advance() bool {
 //
 // Will error with io.EOF if you're past the end, loop HasNext() to prevent.
 func (reader *DataFileReader) Next(v interface{}) error {
-	if !reader.advance() {
-		return reader.err
-	}
-
-	err := reader.datum.Read(v, reader.block.decoder)
-	if err != nil {
-		return err
+	for reader.advance() {
+		err := reader.datum.Read(v, reader.block.decoder)
+		if err == nil {
+			reader.block.BlockRemaining--
+			return nil
+		}
+		if !reader.skipCorrupted {
+			return err
+		}
+		// the rest of the block can't be trusted, so give up on it
+		reader.skipBlock(err)
 	}
-	reader.block.BlockRemaining--
-	return nil
+	return reader.err
 }
 
 // NextBlock tells this DataFileReader to skip current block and move to next one.
@@ -206,186 +485,778 @@ func (reader *DataFileReader) Next(v interface{}) error {
 // May return an error if the block is malformed or io.EOF if no more blocks
 // left to read.
 func (reader *DataFileReader) NextBlock() error {
-	if err := reader.actualNextBlock(); err != nil {
+	err := reader.actualNextBlock()
+	if err != nil && err != io.EOF && reader.skipCorrupted {
+		err = reader.resync(err)
+	}
+	if err != nil {
 		return reader.stop(err)
-	} else {
-		return err
 	}
+	return nil
 }
 
 // actualNextBlock is separated so we don't need to put reader.stop on all error returns
 func (reader *DataFileReader) actualNextBlock() error {
-	// Close out the current block
-	if block := reader.block; block != nil {
-		// Drain out what's remaining into dev/null.
-		// If we're at the end of a block, shouldn't actually copy anything.
-		_, err := io.CopyBuffer(ioutil.Discard, block.reader, reader.sharedCopyBuf)
-		if err != nil {
-			return err
+	reader.block = nil
+	err := reader.readBlock()
+	for err == errNewHeader {
+		if err = reader.readHeader(); err == nil {
+			err = reader.readBlock()
 		}
+	}
+	return err
+}
 
-		block.runCloser()
+// Happens when a block is expected but the header of a concatenated file was found.
+var errNewHeader = errors.New("DataFileReader: New header")
 
-		// Check the sync data at end of block is equal
-		syncBuffer := reader.sharedCopyBuf[:containerSyncSize]
-		_, err = io.ReadFull(reader.r, syncBuffer)
-		if err != nil {
-			return err
-		}
-		if !bytes.Equal(syncBuffer, reader.header.Sync) {
-			return fmt.Errorf("was expecting sync %v, got %v", reader.header.Sync, syncBuffer)
+func (reader *DataFileReader) readBlock() error {
+	if reader.readAhead > 0 {
+		return reader.nextBlockAhead()
+	}
+
+	raw, err := reader.readRawBlock(reader.compressed)
+	reader.blockStart, reader.blockEnd = raw.start, raw.end
+	if err != nil {
+		reader.raw = raw
+		return err
+	}
+	reader.compressed = raw.compressed
+	reader.setBlock(raw, nil)
+	return nil
+}
+
+// rawBlock is a block as stored in the input, before decompression.
+type rawBlock struct {
+	// offsets of the block in the input, start is just past the preceding
+	// sync marker and end just past the following one
+	start, end int64
+	count      int64
+	compressed []byte
+}
+
+// readRawBlock reads the next block from the input, buf is reused for
+// its data if it is large enough.
+func (reader *DataFileReader) readRawBlock(buf []byte) (raw rawBlock, err error) {
+	raw.start = reader.in.pos
+	defer func() { raw.end = reader.in.pos }()
+
+	// A block never starts with the magic as it would be a negative count.
+	if reader.concatenated {
+		if next, _ := reader.in.Peek(len(magic)); bytes.Equal(next, magic) {
+			return raw, errNewHeader
 		}
-		reader.block = nil
 	}
 
 	// Read counts for the new block
-	blockCount, err := reader.dec.ReadLong()
-	if err != nil {
+	if raw.count, err = reader.dec.ReadLong(); err != nil {
 		// This is the only time an "unexpected EOF" may actually be expected.
 		if err == ErrUnexpectedEOF {
 			err = io.EOF
 		}
-		return err
+		return raw, err
 	}
 
 	blockSize, err := reader.dec.ReadLong()
 	if err != nil {
-		return err
+		return raw, err
 	}
 
 	if blockSize > math.MaxInt32 || blockSize < 0 {
-		return fmt.Errorf("Block size invalid or too large: %d", blockSize)
+		return raw, fmt.Errorf("Block size invalid or too large: %d", blockSize)
+	}
+	if max := reader.limits.MaxBlockSize; max > 0 && blockSize > max {
+		return raw, &LimitError{"MaxBlockSize", max, blockSize}
 	}
 
-	// Pipeline step 1: io.LimitReader ensures we don't read past the end of the block.
-	r := io.LimitReader(reader.r, blockSize)
-
-	// Pipeline step 2: Buffer for performance on underlying file object.
-	// Normally, bufio.Reader would read too far, but LimitReader prevents it.
-	r = bufio.NewReader(r)
+	// The whole block is read at once because most codecs can only
+	// decompress complete blocks.
+	if cap(buf) < int(blockSize) {
+		buf = make([]byte, blockSize)
+	}
+	raw.compressed = buf[:blockSize]
+	if _, err = io.ReadFull(reader.in, raw.compressed); err != nil {
+		return raw, eofUnexpected(err)
+	}
 
-	// Pipeline step 3: Use any decoder given by the codec for this block
+	// Check the sync data at end of block is equal
+	syncBuffer := reader.syncBuf[:]
+	if _, err = io.ReadFull(reader.in, syncBuffer); err != nil {
+		return raw, eofUnexpected(err)
+	}
+	if !bytes.Equal(syncBuffer, reader.header.Sync) {
+		return raw, fmt.Errorf("was expecting sync %v, got %v", reader.header.Sync, syncBuffer)
+	}
+	return raw, nil
+}
 
-	r, closer := reader.codec.CodecReader(r)
+func (reader *DataFileReader) decompress(dst, compressed []byte) ([]byte, error) {
+	data, err := decompressLimited(reader.codec, dst, compressed, reader.limits.MaxBlockSize)
+	if err != nil {
+		if limitErr, ok := err.(*LimitError); ok {
+			return nil, limitErr
+		}
+		return nil, fmt.Errorf("DataFileReader: Error decompressing block: %s", err.Error())
+	}
+	return data, nil
+}
 
-	block := &DataBlock{
-		reader:         r,
-		closer:         closer,
-		decoder:        NewBinaryDecoderReader(r),
-		BlockRemaining: blockCount,
-		NumEntries:     blockCount,
-		BlockSize:      int(blockSize),
+// setBlock makes raw the current block, data is nil unless it has already been decompressed.
+func (reader *DataFileReader) setBlock(raw rawBlock, data []byte) {
+	reader.raw, reader.rawConsumed = raw, false
+	reader.block = &DataBlock{
+		BlockRemaining: raw.count,
+		NumEntries:     raw.count,
+		BlockSize:      len(raw.compressed),
+	}
+	if data != nil {
+		reader.block.data, reader.block.decoder = data, NewBinaryDecoderWithLimits(data, reader.limits)
 	}
-	reader.block = block
 	reader.err = nil
+}
 
+func (reader *DataFileReader) decompressBlock() error {
+	data, err := reader.decompress(reader.data[:0], reader.raw.compressed)
+	if err != nil {
+		return err
+	}
+	reader.data = data
+	reader.block.data, reader.block.decoder = data, NewBinaryDecoderWithLimits(data, reader.limits)
 	return nil
 }
 
+// RawBlock is a data block of an object container file as it is stored in
+// the file, i.e. still compressed.
+type RawBlock struct {
+	// Count is the number of records in the block.
+	Count int64
+
+	// Data holds the records of the block compressed with Codec.
+	Data []byte
+
+	// Codec is the name of the codec Data is compressed with.
+	Codec string
+}
+
+// NextRawBlock returns the current block without decompressing or decoding
+// it and moves on to the next one, or returns io.EOF if there are no more
+// blocks. Together with DataFileWriter.WriteRawBlock it copies blocks from
+// one file to another without touching the records in them.
+//
+// The whole block is returned even if some of its records have already been
+// read with Next. The Data of the block is only valid until the next call
+// to the reader.
+func (reader *DataFileReader) NextRawBlock() (RawBlock, error) {
+	if reader.block != nil && reader.rawConsumed {
+		reader.NextBlock()
+	}
+	if reader.block == nil {
+		if reader.err == nil {
+			return RawBlock{}, io.EOF
+		}
+		return RawBlock{}, reader.err
+	}
+	reader.rawConsumed = true
+	reader.block.BlockRemaining = 0
+	return RawBlock{
+		Count: reader.raw.count,
+		Data:  reader.raw.compressed,
+		Codec: reader.Codec(),
+	}, nil
+}
+
 // Close the underlying file if necessary.
 //
 // Needed with filesystem files if you want to not leak filehandles.
 // Returns any error in closing.
+//
+// Close doesn't wait for a read ahead blocked reading the input. Closing an
+// input which is an io.Closer unblocks it, otherwise its goroutine exits
+// once the read returns.
 func (reader *DataFileReader) Close() error {
-	if block := reader.block; block != nil {
-		block.runCloser()
+	reader.block = nil
+	var err error
+	if reader.closer != nil {
+		// closing first unblocks a read ahead waiting for the input
+		err = reader.closer.Close()
+	}
+	reader.abandonReadAhead()
+	return err
+}
+
+// Tell returns the offset in the input of the next byte the reader will
+// consume. As whole blocks are read at once this is the end of the current
+// block, which is also where the next one starts.
+func (reader *DataFileReader) Tell() int64 {
+	return reader.blockEnd
+}
+
+// PreviousSync returns the offset of the sync point preceding the current
+// block, i.e. the position just past the sync marker after which the block
+// starts. It can be passed to SeekBlock to read the block again later.
+func (reader *DataFileReader) PreviousSync() int64 {
+	return reader.blockStart
+}
+
+// PastSync tells if the reader has moved past the first sync marker at or
+// after the given position. Together with Sync this splits a file into byte
+// ranges where each block belongs to the range its preceding sync marker
+// starts in, so that every block is read exactly once:
+//
+//	if err := reader.Sync(start); err != nil {
+//		return err
+//	}
+//	for reader.HasNext() && !reader.PastSync(end) {
+//		...
+//	}
+func (reader *DataFileReader) PastSync(position int64) bool {
+	return reader.block == nil || reader.blockStart >= position+containerSyncSize
+}
+
+// Sync moves the reader to the first block starting after a sync marker at
+// or after the given position, which may be any offset in the input. If
+// there is no such sync marker the reader is left at the end of the input.
+//
+// Returns ErrNotSeekable, leaving the reader where it is, if the input does
+// not support seeking.
+func (reader *DataFileReader) Sync(position int64) error {
+	if reader.seeker == nil {
+		return ErrNotSeekable
+	}
+	if err := reader.seek(position); err != nil {
+		return reader.stop(err)
 	}
-	if closer, ok := reader.r.(io.Closer); ok {
-		return closer.Close()
+	if err := reader.scanSync(); err != nil {
+		reader.blockStart, reader.blockEnd = reader.in.pos, reader.in.pos
+		reader.stop(err)
+		return reader.Err()
+	}
+	return reader.nextBlockAt()
+}
+
+// SeekBlock moves the reader to a block starting at exactly the given position,
+// such as an offset previously returned by PreviousSync.
+//
+// Returns ErrNotSeekable, leaving the reader where it is, if the input does
+// not support seeking.
+func (reader *DataFileReader) SeekBlock(position int64) error {
+	if reader.seeker == nil {
+		return ErrNotSeekable
+	}
+	if err := reader.seek(position); err != nil {
+		return reader.stop(err)
+	}
+	return reader.nextBlockAt()
+}
+
+// nextBlockAt reads the block at the current position of the input, which
+// may legitimately be the end of it.
+func (reader *DataFileReader) nextBlockAt() error {
+	if err := reader.NextBlock(); err != nil && err != io.EOF {
+		return err
 	}
 	return nil
 }
 
+func (reader *DataFileReader) seek(position int64) error {
+	if reader.seeker == nil {
+		return ErrNotSeekable
+	}
+	reader.stopReadAhead()
+	reader.block = nil
+	reader.err = nil
+	if _, err := reader.seeker.Seek(position, io.SeekStart); err != nil {
+		return err
+	}
+	reader.in.Reset(reader.r, position)
+	return nil
+}
+
+// scanSync consumes the input up to and including the next sync marker,
+// returns io.EOF if there is none.
+func (reader *DataFileReader) scanSync() error {
+	in := reader.in
+	for {
+		window, err := in.Peek(in.Size())
+		if i := bytes.Index(window, reader.header.Sync); i >= 0 {
+			_, err = in.Discard(i + containerSyncSize)
+			return err
+		}
+		if err != nil {
+			in.Discard(len(window))
+			return err
+		}
+		// keep the tail in case the marker straddles the window
+		in.Discard(len(window) - (containerSyncSize - 1))
+	}
+}
+
+// SkippedRange is a part of the input skipped by a DataFileReader reading
+// with DataFileReaderOptions.SkipCorrupted.
+type SkippedRange struct {
+	// Start and End are the offsets of the skipped bytes in the input.
+	Start, End int64
+
+	// Records is the number of records known to be lost. Records in parts
+	// of the input which could not be read at all can't be counted.
+	Records int64
+
+	// Err is the error which caused the range to be skipped.
+	Err error
+}
+
+// SkipStats sums up everything a DataFileReader has skipped.
+type SkipStats struct {
+	Ranges  int
+	Bytes   int64
+	Records int64
+}
+
+// Skipped returns the totals of the ranges skipped so far because of
+// DataFileReaderOptions.SkipCorrupted.
+func (reader *DataFileReader) Skipped() SkipStats {
+	return reader.skipped
+}
+
+func (reader *DataFileReader) skip(skipped SkippedRange) {
+	if skipped.Records < 0 {
+		skipped.Records = 0
+	}
+	reader.skipped.Ranges++
+	reader.skipped.Bytes += skipped.End - skipped.Start
+	reader.skipped.Records += skipped.Records
+	if reader.onSkip != nil {
+		reader.onSkip(skipped)
+	}
+}
+
+// skipBlock skips what is left of the current block.
+func (reader *DataFileReader) skipBlock(err error) {
+	reader.skip(SkippedRange{
+		Start:   reader.blockStart,
+		End:     reader.blockEnd,
+		Records: reader.block.BlockRemaining,
+		Err:     err,
+	})
+	reader.block.BlockRemaining = 0
+}
+
+// resync recovers from a block which could not be read by moving on to
+// the block after the next sync marker.
+func (reader *DataFileReader) resync(err error) error {
+	for err != nil && err != io.EOF {
+		start, records := reader.blockStart, reader.raw.count
+		if reader.seeker != nil {
+			if seekErr := reader.seek(start); seekErr != nil {
+				return seekErr
+			}
+		} else {
+			reader.stopReadAhead()
+		}
+		scanErr := reader.scanSync()
+		reader.skip(SkippedRange{Start: start, End: reader.in.pos, Records: records, Err: err})
+		if scanErr != nil {
+			return scanErr
+		}
+		err = reader.actualNextBlock()
+	}
+	return err
+}
+
 ////////// DATA FILE WRITER
 
 // DataFileWriter lets you write object container files.
 type DataFileWriter struct {
-	output      io.Writer
+	// guards all other fields, but the output belongs to the pipeline if set
+	mu sync.Mutex
+
+	output      *countingWriter
 	outputEnc   *binaryEncoder
 	datumWriter DatumWriter
 	sync        []byte
+	codec       Codec
+	codecName   string
 
 	// current block is buffered until flush
 	blockBuf   *bytes.Buffer
 	blockCount int64
 	blockEnc   *binaryEncoder
+
+	// holds the compressed block, reused between flushes
+	compressed []byte
+
+	// compresses and writes blocks in the background if set
+	pipeline *blockWritePipeline
+
+	// offsets of the blocks written, if requested
+	index *BlockIndex
+
+	// thresholds for flushing blocks automatically, no limit if <= 0
+	syncInterval int
+	syncRecords  int64
+}
+
+// DefaultSyncInterval is the default DataFileWriterOptions.SyncInterval.
+const DefaultSyncInterval = 64000
+
+// countingWriter keeps track of the offset in the output of the next byte written.
+type countingWriter struct {
+	io.Writer
+	pos int64
+}
+
+func (w *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	atomic.AddInt64(&w.pos, int64(n))
+	return
+}
+
+// written may be called while another goroutine is writing.
+func (w *countingWriter) written() int64 {
+	return atomic.LoadInt64(&w.pos)
+}
+
+// DataFileWriterOptions configures a DataFileWriter.
+// The zero value writes uncompressed files.
+type DataFileWriterOptions struct {
+	// Codec is the name of the codec used to compress data blocks:
+	// "null" (default), "deflate", "snappy", "zstandard", "bzip2", "xz"
+	// or any other name added with RegisterCodec.
+	Codec string
+
+	// SyncMarker is the 16-byte marker written after every block.
+	// If empty, a random marker is generated for each file, which is what
+	// should be used unless the output needs to be reproducible, e.g. in tests.
+	SyncMarker []byte
+
+	// Meta holds user metadata to be stored in the file header.
+	// Keys in the "avro." namespace are reserved and will be rejected.
+	Meta map[string][]byte
+
+	// SyncInterval is the approximate size in bytes of the uncompressed data
+	// of a block, once it is reached the block is flushed automatically.
+	// Defaults to 64000 like the Java implementation, a negative value turns
+	// automatic flushing by size off.
+	SyncInterval int
+
+	// SyncRecords is the number of records after which a block is flushed
+	// automatically. If zero, blocks are not limited by their record count.
+	SyncRecords int
+
+	// BuildIndex makes the writer keep a BlockIndex of the blocks it writes,
+	// which is available from DataFileWriter.Index after Close. Not
+	// supported when appending to a file.
+	BuildIndex bool
+
+	// Concurrency is the number of goroutines compressing blocks in the
+	// background. If set, Flush hands the current block over and returns
+	// while a new block is filled, blocks are still written in order. At most
+	// this many blocks are pending at once, Flush waits when there are more.
+	// If zero, blocks are compressed and written by the goroutine calling Flush.
+	Concurrency int
 }
 
 // NewDataFileWriter creates a new DataFileWriter for given output and schema using the given DatumWriter to write the data to that Writer.
 // May return an error if writing fails.
 func NewDataFileWriter(output io.Writer, schema Schema, datumWriter DatumWriter) (writer *DataFileWriter, err error) {
-	encoder := newBinaryEncoder(output)
-	switch w := datumWriter.(type) {
-	case *SpecificDatumWriter:
-		w.SetSchema(schema)
-	case *GenericDatumWriter:
-		w.SetSchema(schema)
+	return NewDataFileWriterWithOptions(output, schema, datumWriter, DataFileWriterOptions{})
+}
+
+// NewDataFileWriterWithOptions is like NewDataFileWriter but allows the
+// file to be configured, e.g. to choose a compression codec.
+// May return an error if the options are invalid or writing fails.
+func NewDataFileWriterWithOptions(output io.Writer, schema Schema, datumWriter DatumWriter, options DataFileWriterOptions) (writer *DataFileWriter, err error) {
+	codecName := options.Codec
+	if codecName == "" {
+		codecName = codecNull
+	}
+	if lookupCodec(codecName) == nil {
+		return nil, fmt.Errorf("DataFileWriter: Don't know how to encode codec %s", codecName)
 	}
 
-	sync := []byte("1234567890abcdef") // TODO come up with other sync value
+	sync := options.SyncMarker
+	if len(sync) == 0 {
+		sync = make([]byte, containerSyncSize)
+		if _, err = rand.Read(sync); err != nil {
+			return nil, err
+		}
+	} else if len(sync) != containerSyncSize {
+		return nil, fmt.Errorf("DataFileWriter: Sync marker must be %d bytes, got %d", containerSyncSize, len(sync))
+	}
+
+	meta := make(map[string][]byte, len(options.Meta)+2)
+	for key, value := range options.Meta {
+		if strings.HasPrefix(key, reservedMetaPrefix) {
+			return nil, fmt.Errorf("DataFileWriter: Metadata key %s is reserved", key)
+		}
+		meta[key] = value
+	}
+	meta[schemaKey] = []byte(schema.String())
+	meta[codecKey] = []byte(codecName)
 
 	header := &objFileHeader{
 		Magic: magic,
-		Meta: map[string][]byte{
-			schemaKey: []byte(schema.String()),
-			codecKey:  []byte("null"),
-		},
-		Sync: sync,
+		Meta:  meta,
+		Sync:  sync,
+	}
+	counter := &countingWriter{Writer: output}
+	enc := newBufferedBinaryEncoder(counter)
+	writeObjFileHeader(enc, header)
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return newDataFileWriter(counter, schema, datumWriter, codecName, sync, options), nil
+}
+
+// OpenDataFileWriterForAppend continues writing to an existing object
+// container file, e.g. one written by a process before it was restarted.
+// The header of the file is read from the start of the input and new
+// blocks are appended at its end using the codec and sync marker of the
+// file. The file must be complete, as left by DataFileWriter.Close. The
+// empty block which Close ends the file with is overwritten, and the file
+// truncated there if it has a Truncate method like *os.File, so that the
+// file has no empty blocks in the middle.
+//
+// The schema must be the one the file was written with, pass nil to use the
+// schema from the file header. Options which determine the header must
+// either be left empty or match the file, Meta cannot be set at all.
+func OpenDataFileWriterForAppend(file io.ReadWriteSeeker, schema Schema, datumWriter DatumWriter, options DataFileWriterOptions) (*DataFileWriter, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header, err := readObjFileHeader(NewBinaryDecoderReader(bufio.NewReader(file)))
+	if err != nil {
+		return nil, fmt.Errorf("DataFileWriter: Error reading header: %s", err.Error())
+	}
+	if !bytes.Equal(header.Magic, magic) {
+		return nil, ErrNotAvroFile
+	}
+
+	fileSchema, err := ParseSchema(string(header.Meta[schemaKey]))
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		schema = fileSchema
+	} else if same, err := sameSchema(schema, fileSchema); err != nil {
+		return nil, err
+	} else if !same {
+		return nil, fmt.Errorf("DataFileWriter: Schema does not match the schema of the file %s", fileSchema)
+	}
+
+	codecName := string(header.Meta[codecKey])
+	if codecName == "" {
+		codecName = codecNull
+	}
+	if options.Codec != "" && options.Codec != codecName {
+		return nil, fmt.Errorf("DataFileWriter: Codec %s does not match the codec of the file %s", options.Codec, codecName)
+	}
+	if lookupCodec(codecName) == nil {
+		return nil, fmt.Errorf("DataFileWriter: Don't know how to encode codec %s", codecName)
+	}
+	if len(options.SyncMarker) > 0 && !bytes.Equal(options.SyncMarker, header.Sync) {
+		return nil, fmt.Errorf("DataFileWriter: Sync marker does not match the sync marker of the file")
+	}
+	if len(options.Meta) > 0 {
+		return nil, fmt.Errorf("DataFileWriter: Cannot change the metadata of an existing file")
+	}
+	if options.BuildIndex {
+		return nil, fmt.Errorf("DataFileWriter: Cannot build an index when appending, use DataFileReader.BuildIndex")
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	end, err := appendOffset(file, size, header.Sync)
+	if err != nil {
+		return nil, err
+	}
+	if end < size {
+		if _, err := file.Seek(end, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if truncater, ok := file.(interface{ Truncate(int64) error }); ok {
+			if err := truncater.Truncate(end); err != nil {
+				return nil, err
+			}
+		}
+	}
+	counter := &countingWriter{Writer: file, pos: end}
+	return newDataFileWriter(counter, schema, datumWriter, codecName, header.Sync, options), nil
+}
+
+// Bounds the size of the empty block DataFileWriter.Close ends a file with,
+// whatever the codec.
+const maxEmptyBlockSize = 1024
+
+// appendOffset returns the offset at which blocks are appended to a file of
+// the given size: the start of the empty block the file ends with if it was
+// written by DataFileWriter.Close, as some readers stop at an empty block,
+// otherwise its end.
+func appendOffset(file io.ReadSeeker, size int64, sync []byte) (int64, error) {
+	tailSize := int64(maxEmptyBlockSize)
+	if size < tailSize {
+		tailSize = size
+	}
+	if _, err := file.Seek(size-tailSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail := make([]byte, tailSize)
+	if _, err := io.ReadFull(file, tail); err != nil {
+		return 0, err
+	}
+	if !bytes.HasSuffix(tail, sync) {
+		return size, nil
+	}
+	// the last block starts after the sync marker before the last one, which
+	// may be the one ending the header
+	start := bytes.LastIndex(tail[:len(tail)-len(sync)], sync)
+	if start < 0 {
+		return size, nil
+	}
+	start += len(sync)
+	dec := &binaryDecoder{buf: tail[start : len(tail)-len(sync)]}
+	if count, err := dec.ReadLong(); err != nil || count != 0 {
+		return size, nil
+	}
+	if blockSize, err := dec.ReadLong(); err != nil || blockSize != int64(len(dec.buf))-dec.pos {
+		return size, nil
+	}
+	return size - tailSize + int64(start), nil
+}
+
+// sameSchema compares the canonical forms of two schemas.
+func sameSchema(a, b Schema) (bool, error) {
+	if a == nil || b == nil {
+		return a == b, nil
 	}
-	headerWriter := NewSpecificDatumWriter()
-	headerWriter.SetSchema(objHeaderSchema)
-	if err = headerWriter.Write(header, encoder); err != nil {
-		return
+	fa, err := a.Fingerprint()
+	if err != nil {
+		return false, err
+	}
+	fb, err := b.Fingerprint()
+	if err != nil {
+		return false, err
+	}
+	return fa.Equal(fb), nil
+}
+
+// newDataFileWriter sets up a writer for output where the header has already been written.
+func newDataFileWriter(output *countingWriter, schema Schema, datumWriter DatumWriter, codecName string, sync []byte, options DataFileWriterOptions) *DataFileWriter {
+	switch w := datumWriter.(type) {
+	case *SpecificDatumWriter:
+		w.SetSchema(schema)
+	case *GenericDatumWriter:
+		w.SetSchema(schema)
 	}
+
 	blockBuf := &bytes.Buffer{}
-	writer = &DataFileWriter{
+	writer := &DataFileWriter{
 		output:      output,
-		outputEnc:   encoder,
+		outputEnc:   newBufferedBinaryEncoder(output),
 		datumWriter: datumWriter,
 		sync:        sync,
+		codec:       lookupCodec(codecName),
+		codecName:   codecName,
 		blockBuf:    blockBuf,
 		blockEnc:    newBinaryEncoder(blockBuf),
-	}
 
-	return
+		syncInterval: options.SyncInterval,
+		syncRecords:  int64(options.SyncRecords),
+	}
+	if writer.syncInterval == 0 {
+		writer.syncInterval = DefaultSyncInterval
+	}
+	if options.BuildIndex {
+		writer.index = &BlockIndex{SyncMarker: sync}
+	}
+	if options.Concurrency > 0 {
+		writer.pipeline = newBlockWritePipeline(writer, options.Concurrency)
+	}
+	return writer
 }
 
 // Write out a single datum.
 //
 // Encoded datums are buffered internally and will not be written to the
-// underlying io.Writer until Flush() is called.
+// underlying io.Writer until the block reaches one of the sync thresholds
+// set in DataFileWriterOptions or Flush() is called. Write may be called
+// from several goroutines at once.
 func (w *DataFileWriter) Write(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.pipelineErr(); err != nil {
+		return err
+	}
+	mark := w.blockBuf.Len()
+	if err := w.datumWriter.Write(v, w.blockEnc); err != nil {
+		// drop whatever part of the datum was encoded to keep the block valid
+		w.blockBuf.Truncate(mark)
+		return err
+	}
 	w.blockCount++
-	err := w.datumWriter.Write(v, w.blockEnc)
-	return err
+	if (w.syncInterval > 0 && w.blockBuf.Len() >= w.syncInterval) ||
+		(w.syncRecords > 0 && w.blockCount >= w.syncRecords) {
+		return w.actuallyFlush()
+	}
+	return nil
 }
 
 // Flush out any previously written datums to our underlying io.Writer.
 // Does nothing if no datums had previously been written.
 //
-// It's up to the library user to decide how often to flush; doing it
-// often will spend a lot of time on tiny I/O but save memory.
+// Blocks are flushed automatically according to the sync thresholds, so
+// this is only needed to end a block early.
+//
+// With DataFileWriterOptions.Concurrency set the block is only handed over
+// to be compressed and written in the background, an error doing so is
+// returned by a later call.
 func (w *DataFileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// Sync flushes the current block, if any, and returns the offset in the
+// output at which the next block will start. The offset can be passed to
+// DataFileReader.SeekBlock to jump straight to the records written after
+// it, which allows building an index of a file while writing it.
+//
+// Unlike Flush, Sync waits for all blocks to be written when
+// DataFileWriterOptions.Concurrency is set.
+func (w *DataFileWriter) Sync() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	if w.pipeline != nil {
+		if err := w.pipeline.wait(); err != nil {
+			return 0, err
+		}
+	}
+	return w.output.written(), nil
+}
+
+func (w *DataFileWriter) flush() error {
 	if w.blockCount > 0 {
 		return w.actuallyFlush()
 	}
-	return nil
+	return w.pipelineErr()
 }
 
 func (w *DataFileWriter) actuallyFlush() error {
-	// Write the block count and length directly to output
-	w.outputEnc.WriteLong(w.blockCount)
-	w.outputEnc.WriteLong(int64(w.blockBuf.Len()))
+	if w.pipeline != nil {
+		return w.pipeline.flush(w)
+	}
 
-	// copy the buffer which is the block buf to output
-	_, err := io.Copy(w.output, w.blockBuf)
+	compressed, err := w.codec.Compress(w.compressed[:0], w.blockBuf.Bytes())
 	if err != nil {
 		return err
 	}
-
-	// write the sync bytes
-	_, err = w.output.Write(w.sync)
-	if err != nil {
+	w.compressed = compressed
+	if err = w.writeBlock(w.blockCount, compressed); err != nil {
 		return err
 	}
 
@@ -394,45 +1265,92 @@ func (w *DataFileWriter) actuallyFlush() error {
 	return nil
 }
 
+// WriteRawBlock writes a block read with DataFileReader.NextRawBlock after
+// flushing any records written before it. Blocks with the codec of this
+// writer are copied verbatim, otherwise only the block data is transcoded
+// to this codec. In either case the records in it are not decoded, so they
+// must have been written with the same schema as this file.
+func (w *DataFileWriter) WriteRawBlock(block RawBlock) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	compressed := block.Data
+	if block.Codec != w.codecName {
+		codec := lookupCodec(block.Codec)
+		if codec == nil {
+			return fmt.Errorf("DataFileWriter: Don't know how to decode codec %s", block.Codec)
+		}
+		data, err := codec.Decompress(nil, block.Data)
+		if err != nil {
+			return fmt.Errorf("DataFileWriter: Error decompressing block: %s", err.Error())
+		}
+		if compressed, err = w.codec.Compress(nil, data); err != nil {
+			return err
+		}
+	}
+	if w.pipeline != nil {
+		return w.pipeline.writeCompressed(block.Count, compressed)
+	}
+	return w.writeBlock(block.Count, compressed)
+}
+
+// writeBlock writes a compressed block followed by the sync marker to output.
+func (w *DataFileWriter) writeBlock(count int64, compressed []byte) error {
+	if w.index != nil {
+		w.index.add(w.output.written(), count)
+	}
+
+	w.outputEnc.WriteLong(count)
+	w.outputEnc.WriteLong(int64(len(compressed)))
+	w.outputEnc.WriteRaw(compressed)
+	w.outputEnc.WriteRaw(w.sync)
+	return w.outputEnc.Flush()
+}
+
+// Index returns the index of the file if DataFileWriterOptions.BuildIndex
+// was set, nil otherwise. It is only complete once the writer is closed.
+func (w *DataFileWriter) Index() *BlockIndex {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pipeline != nil {
+		w.pipeline.wait()
+	}
+	return w.index
+}
+
 // Close this DataFileWriter.
 // This is required to finish out the data file format.
 // After Close() is called, this DataFileWriter cannot be used anymore.
+//
+// With DataFileWriterOptions.Concurrency set, Close waits until all blocks
+// have been written and returns the first error encountered by any of them.
 func (w *DataFileWriter) Close() error {
-	err := w.Flush() // flush anything remaining
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flush() // flush anything remaining
 	if err == nil {
 		// Do an empty flush to signal end of data file format
 		err = w.actuallyFlush()
-
-		if err == nil {
-			// Clean up references.
-			w.output, w.outputEnc, w.datumWriter = nil, nil, nil
-			w.blockBuf, w.blockEnc = nil, nil
+	}
+	if w.pipeline != nil {
+		if pipelineErr := w.pipeline.close(); err == nil {
+			err = pipelineErr
 		}
 	}
+	if err == nil {
+		// Clean up references.
+		w.output, w.outputEnc, w.datumWriter = nil, nil, nil
+		w.blockBuf, w.blockEnc, w.compressed = nil, nil, nil
+	}
 	return err
 }
 
-type fileCodec interface {
-	CodecReader(io.Reader) (io.Reader, func())
-}
-
-type nullCodec struct{}
-
-func (nullCodec) CodecReader(r io.Reader) (io.Reader, func()) {
-	return r, nil
-}
-
-type flateCodec struct{}
-
-func (flateCodec) CodecReader(r io.Reader) (io.Reader, func()) {
-	flateReader := flate.NewReader(r)
-	return flateReader, func() { flateReader.Close() }
-}
-
 // DataBlock is a structure that holds a certain amount of entries and the actual buffer to read from.
 type DataBlock struct {
-	reader  io.Reader
-	closer  func()
+	data    []byte
 	decoder Decoder
 
 	// Number of entries encoded in Data.
@@ -444,10 +1362,3 @@ type DataBlock struct {
 	// Number of unread entries in this DataBlock.
 	BlockRemaining int64
 }
-
-func (block *DataBlock) runCloser() {
-	if block.closer != nil {
-		block.closer()
-		block.closer = nil
-	}
-}
@@ -0,0 +1,348 @@
+package avro
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	dsbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Block compression codecs for object container files.
+// Spec: https://avro.apache.org/docs/current/spec.html#Required+Codecs
+
+const (
+	codecNull      = "null"
+	codecDeflate   = "deflate"
+	codecSnappy    = "snappy"
+	codecZstandard = "zstandard"
+	codecBzip2     = "bzip2"
+	codecXZ        = "xz"
+)
+
+// Happens when the CRC32 trailing a snappy compressed block does not match its content.
+var errSnappyChecksum = errors.New("snappy block checksum mismatch")
+
+// Codec compresses and decompresses the data blocks of object container files.
+// Both methods append their output to dst and return the extended slice.
+//
+// A Codec may be used by many readers and writers at once, so implementations
+// must be safe for concurrent use.
+type Codec interface {
+	// Compress appends the compressed form of src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress appends the decompressed form of src to dst.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// LimitedCodec is a Codec which can stop decompressing a block as soon as its
+// decompressed size exceeds a maximum. DataFileReader uses it to enforce
+// DecoderLimits.MaxBlockSize without inflating more than that, so a small
+// block can't make it allocate gigabytes. All built-in codecs implement it,
+// blocks of other codecs are decompressed completely before the limit is
+// checked.
+type LimitedCodec interface {
+	Codec
+
+	// DecompressLimited is like Decompress, but fails with a *LimitError of
+	// MaxBlockSize once the decompressed form of src exceeds max bytes.
+	DecompressLimited(dst, src []byte, max int64) ([]byte, error)
+}
+
+// decompressLimited decompresses src with codec, failing once the output
+// exceeds max bytes. A max of zero doesn't limit anything.
+func decompressLimited(codec Codec, dst, src []byte, max int64) ([]byte, error) {
+	if max <= 0 {
+		return codec.Decompress(dst, src)
+	}
+	if limited, ok := codec.(LimitedCodec); ok {
+		return limited.DecompressLimited(dst, src, max)
+	}
+	data, err := codec.Decompress(dst, src)
+	if err == nil && int64(len(data)-len(dst)) > max {
+		return dst, blockSizeLimitError(max, int64(len(data)-len(dst)))
+	}
+	return data, err
+}
+
+func blockSizeLimitError(max, size int64) error {
+	return &LimitError{"MaxBlockSize", max, size}
+}
+
+var codecs = map[string]Codec{
+	codecNull:      nullCodec{},
+	codecDeflate:   flateCodec{},
+	codecSnappy:    snappyCodec{},
+	codecZstandard: zstdCodec{},
+	codecBzip2:     bzip2Codec{},
+	codecXZ:        xzCodec{},
+}
+var codecsLock sync.RWMutex
+
+// RegisterCodec makes a codec available to DataFileReader and DataFileWriter
+// under the given name, which is the value stored in the "avro.codec" header
+// of the files it is used for. Registering a name that already exists,
+// including one of the built-in codecs, replaces the previous implementation.
+//
+// Panics if the name is empty or the codec is nil.
+func RegisterCodec(name string, codec Codec) {
+	if name == "" || codec == nil {
+		panic("RegisterCodec: Must provide a name and a non-nil codec.")
+	}
+	codecsLock.Lock()
+	codecs[name] = codec
+	codecsLock.Unlock()
+}
+
+// lookupCodec finds a registered codec, a missing name means "null".
+func lookupCodec(name string) Codec {
+	if name == "" {
+		name = codecNull
+	}
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	return codecs[name]
+}
+
+type nullCodec struct{}
+
+func (nullCodec) Compress(dst, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+func (nullCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+func (nullCodec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	if int64(len(src)) > max {
+		return dst, blockSizeLimitError(max, int64(len(src)))
+	}
+	return append(dst, src...), nil
+}
+
+type flateCodec struct{}
+
+func (flateCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return dst, err
+	}
+	return finishWriter(buf, w, src)
+}
+
+func (flateCodec) Decompress(dst, src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return readAllInto(dst, r)
+}
+
+func (flateCodec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return readLimitedInto(dst, r, max)
+}
+
+// snappyCodec frames each block as snappy data followed by the
+// big-endian CRC32 of the uncompressed data.
+type snappyCodec struct{}
+
+func (snappyCodec) Compress(dst, src []byte) ([]byte, error) {
+	dst = append(dst, snappy.Encode(nil, src)...)
+	var checksum [4]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(src))
+	return append(dst, checksum[:]...), nil
+}
+
+func (snappyCodec) Decompress(dst, src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return dst, ErrUnexpectedEOF
+	}
+	data, err := snappy.Decode(nil, src[:len(src)-4])
+	if err != nil {
+		return dst, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(src[len(src)-4:]) {
+		return dst, errSnappyChecksum
+	}
+	return append(dst, data...), nil
+}
+
+func (c snappyCodec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	if len(src) < 4 {
+		return dst, ErrUnexpectedEOF
+	}
+	// snappy data starts with its decoded length, which is checked before
+	// anything is allocated
+	size, err := snappy.DecodedLen(src[:len(src)-4])
+	if err != nil {
+		return dst, err
+	}
+	if int64(size) > max {
+		return dst, blockSizeLimitError(max, int64(size))
+	}
+	return c.Decompress(dst, src)
+}
+
+// The zstd encoder and decoder are safe for concurrent use of EncodeAll and
+// DecodeAll, so a single instance of each is shared.
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func zstdInit() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr == nil {
+			zstdDecoder, zstdErr = zstd.NewReader(nil)
+		}
+	})
+	return zstdErr
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Compress(dst, src []byte) ([]byte, error) {
+	if err := zstdInit(); err != nil {
+		return dst, err
+	}
+	return zstdEncoder.EncodeAll(src, dst), nil
+}
+
+func (zstdCodec) Decompress(dst, src []byte) ([]byte, error) {
+	if err := zstdInit(); err != nil {
+		return dst, err
+	}
+	return zstdDecoder.DecodeAll(src, dst)
+}
+
+// Largest window a limited zstd decoder allocates when the limit is lower,
+// the default window of streaming encoders.
+const zstdMinLimitedWindow = 8 << 20
+
+// DecompressLimited streams the block through a decoder of its own, as
+// DecodeAll only limits the size of every frame of the block, not their sum.
+func (zstdCodec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	window := uint64(max)
+	if window < zstdMinLimitedWindow {
+		window = zstdMinLimitedWindow
+	}
+	r, err := zstd.NewReader(bytes.NewReader(src), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(window))
+	if err != nil {
+		return dst, err
+	}
+	defer r.Close()
+	return readLimitedInto(dst, r, max)
+}
+
+type bzip2Codec struct{}
+
+func (bzip2Codec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := dsbzip2.NewWriter(buf, nil)
+	if err != nil {
+		return dst, err
+	}
+	return finishWriter(buf, w, src)
+}
+
+func (bzip2Codec) Decompress(dst, src []byte) ([]byte, error) {
+	return readAllInto(dst, bzip2.NewReader(bytes.NewReader(src)))
+}
+
+func (bzip2Codec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	return readLimitedInto(dst, bzip2.NewReader(bytes.NewReader(src)), max)
+}
+
+type xzCodec struct{}
+
+func (xzCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := xz.NewWriter(buf)
+	if err != nil {
+		return dst, err
+	}
+	return finishWriter(buf, w, src)
+}
+
+func (xzCodec) Decompress(dst, src []byte) ([]byte, error) {
+	r, err := xz.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return dst, err
+	}
+	return readAllInto(dst, r)
+}
+
+// DecompressLimited limits the output of the block, but not the dictionary
+// of the LZMA2 decoder, which is allocated at the size the stream declares.
+func (xzCodec) DecompressLimited(dst, src []byte, max int64) ([]byte, error) {
+	r, err := xz.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return dst, err
+	}
+	return readLimitedInto(dst, r, max)
+}
+
+// finishWriter writes src through a compressing writer which wraps buf and
+// returns the buffer contents once the writer has been closed.
+func finishWriter(buf *bytes.Buffer, w io.WriteCloser, src []byte) ([]byte, error) {
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readAllInto reads r until EOF appending everything to dst.
+func readAllInto(dst []byte, r io.Reader) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// readLimitedInto reads r until EOF appending everything to dst, but stops
+// reading as soon as r has more than max bytes. Unlike readAllInto, the
+// buffer never grows past what the limit allows.
+func readLimitedInto(dst []byte, r io.Reader, max int64) ([]byte, error) {
+	end := saturatingAdd(int64(len(dst)), saturatingAdd(max, 1))
+	r = io.LimitReader(r, end-int64(len(dst)))
+	buf := dst
+	for {
+		if len(buf) == cap(buf) && int64(cap(buf)) < end {
+			// double the buffer, growing straight to the limit once
+			// it's within reach
+			size := int64(2*cap(buf) + bytes.MinRead)
+			if size > end/2 {
+				size = end
+			}
+			buf = append(make([]byte, 0, size), buf...)
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	if size := int64(len(buf) - len(dst)); size > max {
+		// r was only read up to one byte past the limit
+		return dst, blockSizeLimitError(max, size)
+	}
+	return buf, nil
+}
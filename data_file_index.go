@@ -0,0 +1,175 @@
+package avro
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// BlockIndex lists the blocks of an object container file with their byte
+// offsets and record counts, which allows DataFileReader.SeekRecord to go
+// straight to the block holding a record. An index is built while writing
+// with DataFileWriterOptions.BuildIndex or from an existing file with
+// DataFileReader.BuildIndex, and can be stored alongside the file with
+// WriteTo and loaded again with ReadBlockIndex.
+type BlockIndex struct {
+	// SyncMarker of the indexed file, used to check the index belongs to it.
+	SyncMarker []byte
+
+	// Blocks in file order, empty blocks are left out.
+	Blocks []IndexedBlock
+}
+
+// IndexedBlock is the position of a single block in a BlockIndex.
+type IndexedBlock struct {
+	// Offset of the block in the file, as returned by DataFileWriter.Sync
+	// and DataFileReader.PreviousSync.
+	Offset int64
+
+	// FirstRecord is the number of records in all blocks before this one.
+	FirstRecord int64
+
+	// Count is the number of records in the block.
+	Count int64
+}
+
+// Records returns the total number of records in the indexed file.
+func (index *BlockIndex) Records() int64 {
+	if len(index.Blocks) == 0 {
+		return 0
+	}
+	last := index.Blocks[len(index.Blocks)-1]
+	return last.FirstRecord + last.Count
+}
+
+func (index *BlockIndex) add(offset, count int64) {
+	if count > 0 {
+		index.Blocks = append(index.Blocks, IndexedBlock{Offset: offset, FirstRecord: index.Records(), Count: count})
+	}
+}
+
+// find returns the block holding record n.
+func (index *BlockIndex) find(n int64) (IndexedBlock, bool) {
+	i := sort.Search(len(index.Blocks), func(i int) bool {
+		return index.Blocks[i].FirstRecord+index.Blocks[i].Count > n
+	})
+	if n < 0 || i == len(index.Blocks) {
+		return IndexedBlock{}, false
+	}
+	return index.Blocks[i], true
+}
+
+// WriteTo writes the index in a compact binary form: the 16-byte sync
+// marker followed by an Avro array of (offset, count) pairs of longs.
+func (index *BlockIndex) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	enc := newBinaryEncoder(buf)
+	enc.WriteRaw(index.SyncMarker)
+	if len(index.Blocks) > 0 {
+		enc.WriteArrayStart(int64(len(index.Blocks)))
+		for _, block := range index.Blocks {
+			enc.WriteLong(block.Offset)
+			enc.WriteLong(block.Count)
+		}
+	}
+	enc.WriteArrayNext(0)
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// ReadBlockIndex reads an index written with BlockIndex.WriteTo. It reads no
+// further than the end of the index, so whatever follows it in r can still
+// be read. As that takes many small reads, wrap an unbuffered r in a
+// bufio.Reader if nothing follows the index.
+func ReadBlockIndex(r io.Reader) (*BlockIndex, error) {
+	dec := NewBinaryDecoderReader(r)
+	index := &BlockIndex{SyncMarker: make([]byte, containerSyncSize)}
+	if err := dec.ReadFixed(index.SyncMarker); err != nil {
+		return nil, fmt.Errorf("BlockIndex: Error reading sync marker: %s", err.Error())
+	}
+	count, err := dec.ReadArrayStart()
+	for ; err == nil && count > 0; count, err = dec.ArrayNext() {
+		for i := int64(0); i < count; i++ {
+			offset, err := dec.ReadLong()
+			if err != nil {
+				return nil, eofUnexpected(err)
+			}
+			records, err := dec.ReadLong()
+			if err != nil {
+				return nil, eofUnexpected(err)
+			}
+			index.add(offset, records)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("BlockIndex: Error reading blocks: %s", err.Error())
+	}
+	return index, nil
+}
+
+// BuildIndex scans the blocks of the file, without decompressing them, to
+// build an index which the reader uses for SeekRecord from then on. The
+// reader is left at the first block. Requires input which supports seeking.
+func (reader *DataFileReader) BuildIndex() (*BlockIndex, error) {
+	if reader.seeker == nil {
+		return nil, ErrNotSeekable
+	}
+	if err := reader.seek(reader.dataStart); err != nil {
+		return nil, reader.stop(err)
+	}
+	index := &BlockIndex{SyncMarker: reader.SyncMarker()}
+	for {
+		raw, err := reader.readRawBlock(reader.compressed)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, reader.stop(err)
+		}
+		reader.compressed = raw.compressed
+		index.add(raw.start, raw.count)
+	}
+	reader.index = index
+	return index, reader.SeekBlock(reader.dataStart)
+}
+
+// SeekRecord moves the reader to the record with the given number, counting
+// from 0, so that the next call to Next reads it. Requires an index, see
+// DataFileReaderOptions.Index and BuildIndex, and input which supports seeking.
+func (reader *DataFileReader) SeekRecord(n int64) error {
+	if reader.index == nil {
+		return fmt.Errorf("DataFileReader: SeekRecord requires an index")
+	}
+	block, ok := reader.index.find(n)
+	if !ok {
+		return fmt.Errorf("DataFileReader: Record %d out of range, the file has %d", n, reader.index.Records())
+	}
+	if err := reader.SeekBlock(block.Offset); err != nil {
+		return err
+	}
+	if reader.block == nil || reader.block.NumEntries != block.Count {
+		return reader.stop(fmt.Errorf("DataFileReader: Index does not match block at %d", block.Offset))
+	}
+
+	// skip the records before the one wanted
+	for i := block.FirstRecord; i < n; i++ {
+		if !reader.advance() {
+			return reader.err
+		}
+		startDatum(reader.block.decoder)
+		if err := SkipDatum(reader.schema, reader.block.decoder); err != nil {
+			return reader.stop(err)
+		}
+		reader.block.BlockRemaining--
+	}
+	return nil
+}
+
+// checkIndex makes sure an index given in the options belongs to the file.
+func (reader *DataFileReader) checkIndex(index *BlockIndex) error {
+	if !bytes.Equal(index.SyncMarker, reader.header.Sync) {
+		return fmt.Errorf("DataFileReader: Index sync marker does not match the file")
+	}
+	reader.index = index
+	return nil
+}
@@ -0,0 +1,127 @@
+package avro
+
+import (
+	"io"
+	"sync"
+)
+
+// blockPipeline reads blocks ahead of the caller of a DataFileReader.
+// A single goroutine reads raw blocks from the input, a pool of workers
+// decompresses them and the caller consumes them in input order from
+// pending. As pending is bounded, so is the number of blocks in memory.
+type blockPipeline struct {
+	pending chan *blockJob // in input order, consumed by the caller
+	work    chan *blockJob // consumed by the workers
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type blockJob struct {
+	raw  rawBlock
+	read bool // the raw block was read successfully
+	data []byte
+	err  error
+	done chan struct{} // closed once data or err is set
+}
+
+func (reader *DataFileReader) startReadAhead() {
+	p := &blockPipeline{
+		pending: make(chan *blockJob, reader.readAhead),
+		work:    make(chan *blockJob, reader.readAhead),
+		quit:    make(chan struct{}),
+	}
+	p.wg.Add(1 + reader.readAhead)
+	go p.read(reader)
+	for i := 0; i < reader.readAhead; i++ {
+		go p.decompress(reader)
+	}
+	reader.ahead = p
+}
+
+// read owns the input of the reader until the pipeline stops.
+func (p *blockPipeline) read(reader *DataFileReader) {
+	defer p.wg.Done()
+	defer close(p.work)
+	defer close(p.pending)
+	for {
+		raw, err := reader.readRawBlock(nil)
+		job := &blockJob{raw: raw, read: err == nil, err: err, done: make(chan struct{})}
+		if err != nil {
+			close(job.done)
+		}
+		select {
+		case p.pending <- job:
+		case <-p.quit:
+			return
+		}
+		if err != nil {
+			return
+		}
+		select {
+		case p.work <- job:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *blockPipeline) decompress(reader *DataFileReader) {
+	defer p.wg.Done()
+	for job := range p.work {
+		select {
+		case <-p.quit:
+			job.err = io.ErrClosedPipe
+		default:
+			job.data, job.err = reader.decompress(nil, job.raw.compressed)
+		}
+		close(job.done)
+	}
+}
+
+// nextBlockAhead takes the next block from the pipeline, starting it if necessary.
+func (reader *DataFileReader) nextBlockAhead() error {
+	if reader.ahead == nil {
+		reader.startReadAhead()
+	}
+	for {
+		job, ok := <-reader.ahead.pending
+		if !ok {
+			// only happens if the pipeline stopped after an error was returned
+			return io.EOF
+		}
+		<-job.done
+		reader.blockStart, reader.blockEnd = job.raw.start, job.raw.end
+		if job.err == nil {
+			reader.setBlock(job.raw, job.data)
+			return nil
+		}
+		if job.read && reader.skipCorrupted {
+			// the following blocks are unaffected if only decompression failed
+			reader.skip(SkippedRange{Start: job.raw.start, End: job.raw.end, Records: job.raw.count, Err: job.err})
+			continue
+		}
+		reader.raw = job.raw
+		reader.stopReadAhead()
+		return job.err
+	}
+}
+
+// stopReadAhead stops the pipeline, if any, and waits for its goroutines so
+// that the input may be used by the caller again.
+func (reader *DataFileReader) stopReadAhead() {
+	if p := reader.ahead; p != nil {
+		reader.ahead = nil
+		close(p.quit)
+		p.wg.Wait()
+	}
+}
+
+// abandonReadAhead stops the pipeline, if any, without waiting for its
+// goroutines. One blocked reading the input exits on its own once the read
+// returns, as the input won't be used again.
+func (reader *DataFileReader) abandonReadAhead() {
+	if p := reader.ahead; p != nil {
+		reader.ahead = nil
+		close(p.quit)
+	}
+}
@@ -0,0 +1,253 @@
+package avro
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// RollingDataFileWriterOptions configures a RollingDataFileWriter.
+// At least one of the thresholds should be set or everything is written
+// to a single file.
+type RollingDataFileWriterOptions struct {
+	// NextFile returns the path of the next file to write, seq counts the
+	// files opened by the writer starting from 0. Files are created with
+	// os.O_EXCL, so a path which already exists is an error. Required.
+	NextFile func(seq int, opened time.Time) string
+
+	// File configures the DataFileWriter of every file.
+	File DataFileWriterOptions
+
+	// MaxBytes rotates to a new file once the blocks written to the current
+	// one add up to this many bytes.
+	MaxBytes int64
+
+	// MaxRecords rotates to a new file once this many records were written
+	// to the current one.
+	MaxRecords int64
+
+	// MaxAge rotates to a new file once the current one has been open this
+	// long, even if no more records are written to it.
+	MaxAge time.Duration
+
+	// OnComplete, if set, is called after a file has been closed. It's called
+	// by the call which finished the file, or by a goroutine of its own for a
+	// rotation by age, without holding a lock of the writer, so it may call
+	// the writer itself but may run concurrently with other calls.
+	OnComplete func(CompletedFile)
+}
+
+// CompletedFile describes a file finished by a RollingDataFileWriter.
+type CompletedFile struct {
+	Path    string
+	Records int64
+	Bytes   int64
+	Opened  time.Time
+	Closed  time.Time
+}
+
+// RollingDataFileWriter writes a stream of records to a sequence of object
+// container files, rotating to the next file when the current one reaches
+// the size, record count or age set in RollingDataFileWriterOptions. Files
+// are only rotated between blocks, each one is a complete container file
+// which is synced to stable storage before it is reported as completed.
+//
+// A file is opened when the first record for it is written, so no empty
+// files are created while there is nothing to write. All methods may be
+// called from several goroutines at once. If a rotation by age fails, the
+// next call returns the error and the writer carries on with a new file.
+type RollingDataFileWriter struct {
+	mu          sync.Mutex
+	schema      Schema
+	datumWriter DatumWriter
+	options     RollingDataFileWriterOptions
+
+	seq     int
+	current *rollingFile
+	err     error // of a rotation by age, returned once by the next call, or errRollingWriterClosed
+}
+
+// Happens when using a RollingDataFileWriter after closing it.
+var errRollingWriterClosed = errors.New("RollingDataFileWriter: Closed")
+
+type rollingFile struct {
+	CompletedFile
+	file   *os.File
+	output *countingWriter
+	writer *DataFileWriter
+	timer  *time.Timer
+}
+
+// NewRollingDataFileWriter creates a RollingDataFileWriter for records of
+// the given schema. No file is created until the first Write.
+func NewRollingDataFileWriter(schema Schema, datumWriter DatumWriter, options RollingDataFileWriterOptions) (*RollingDataFileWriter, error) {
+	if options.NextFile == nil {
+		return nil, errors.New("RollingDataFileWriter: NextFile is required")
+	}
+	return &RollingDataFileWriter{
+		schema:      schema,
+		datumWriter: datumWriter,
+		options:     options,
+	}, nil
+}
+
+// Write writes a single datum to the current file, opening one if there is
+// none, and rotates to the next file if a threshold has been reached.
+func (w *RollingDataFileWriter) Write(v interface{}) error {
+	w.mu.Lock()
+	completed, err := w.write(v)
+	w.mu.Unlock()
+	w.complete(completed)
+	return err
+}
+
+func (w *RollingDataFileWriter) write(v interface{}) (*CompletedFile, error) {
+	if err := w.takeErr(); err != nil {
+		return nil, err
+	}
+	if w.current == nil {
+		if err := w.open(); err != nil {
+			return nil, err
+		}
+	}
+
+	current := w.current
+	if err := current.writer.Write(v); err != nil {
+		return nil, err
+	}
+	current.Records++
+	if (w.options.MaxRecords > 0 && current.Records >= w.options.MaxRecords) ||
+		(w.options.MaxBytes > 0 && current.output.written() >= w.options.MaxBytes) {
+		return w.finish()
+	}
+	return nil, nil
+}
+
+// Flush ends the current block of the current file, see DataFileWriter.Flush.
+func (w *RollingDataFileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.takeErr(); err != nil {
+		return err
+	}
+	if w.current == nil {
+		return nil
+	}
+	return w.current.writer.Flush()
+}
+
+// Rotate finishes the current file, if any. The next Write opens a new one.
+func (w *RollingDataFileWriter) Rotate() error {
+	w.mu.Lock()
+	err := w.takeErr()
+	var completed *CompletedFile
+	if err == nil {
+		completed, err = w.finish()
+	}
+	w.mu.Unlock()
+	w.complete(completed)
+	return err
+}
+
+// Close finishes the current file, if any. The writer can't be used afterwards.
+func (w *RollingDataFileWriter) Close() error {
+	w.mu.Lock()
+	if w.err == errRollingWriterClosed {
+		w.mu.Unlock()
+		return errRollingWriterClosed
+	}
+	completed, err := w.finish()
+	if err == nil {
+		err = w.err
+	}
+	w.err = errRollingWriterClosed
+	w.mu.Unlock()
+	w.complete(completed)
+	return err
+}
+
+// takeErr returns the error of a failed rotation by age once, the next file
+// is opened as usual afterwards. A closed writer always returns an error.
+func (w *RollingDataFileWriter) takeErr() error {
+	err := w.err
+	if err != errRollingWriterClosed {
+		w.err = nil
+	}
+	return err
+}
+
+func (w *RollingDataFileWriter) open() error {
+	opened := time.Now()
+	path := w.options.NextFile(w.seq, opened)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	output := &countingWriter{Writer: file}
+	writer, err := NewDataFileWriterWithOptions(output, w.schema, w.datumWriter, w.options.File)
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	w.seq++
+	current := &rollingFile{
+		CompletedFile: CompletedFile{Path: path, Opened: opened},
+		file:          file,
+		output:        output,
+		writer:        writer,
+	}
+	if w.options.MaxAge > 0 {
+		current.timer = time.AfterFunc(w.options.MaxAge, func() { w.expire(current) })
+	}
+	w.current = current
+	return nil
+}
+
+// expire rotates a file which has reached MaxAge, unless that already happened.
+func (w *RollingDataFileWriter) expire(file *rollingFile) {
+	w.mu.Lock()
+	var completed *CompletedFile
+	if w.current == file && w.err == nil {
+		completed, w.err = w.finish()
+	}
+	w.mu.Unlock()
+	w.complete(completed)
+}
+
+// finish closes the current file and syncs it to stable storage. It returns
+// the file to report once the lock is released, see complete.
+func (w *RollingDataFileWriter) finish() (*CompletedFile, error) {
+	current := w.current
+	if current == nil {
+		return nil, nil
+	}
+	w.current = nil
+	if current.timer != nil {
+		current.timer.Stop()
+	}
+
+	err := current.writer.Close()
+	if err == nil {
+		err = current.file.Sync()
+	}
+	if closeErr := current.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current.Bytes = current.output.written()
+	current.Closed = time.Now()
+	return &current.CompletedFile, nil
+}
+
+// complete reports a finished file, without holding the lock so that
+// OnComplete may use the writer.
+func (w *RollingDataFileWriter) complete(file *CompletedFile) {
+	if file != nil && w.options.OnComplete != nil {
+		w.options.OnComplete(*file)
+	}
+}
@@ -0,0 +1,214 @@
+package avro
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRollingDataFileWriter(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	dir, err := ioutil.TempDir("", "rolling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex // files rotated by age are reported by a goroutine of their own
+	var completed []CompletedFile
+	newWriter := func(prefix string, options RollingDataFileWriterOptions) *RollingDataFileWriter {
+		options.NextFile = func(seq int, opened time.Time) string {
+			return filepath.Join(dir, fmt.Sprintf("%s-%03d.avro", prefix, seq))
+		}
+		options.OnComplete = func(file CompletedFile) {
+			mu.Lock()
+			completed = append(completed, file)
+			mu.Unlock()
+		}
+		w, err := NewRollingDataFileWriter(schema, NewDatumWriter(schema), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	records := func(path string) (n int64) {
+		dfr, err := NewDataFileReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dfr.Close()
+		for ; dfr.HasNext(); n++ {
+			var p primitive
+			assert(t, dfr.Next(&p), nil)
+		}
+		assert(t, dfr.Err(), nil)
+		return
+	}
+
+	// by record count
+	w := newWriter("count", RollingDataFileWriterOptions{MaxRecords: 10})
+	for i := 0; i < 25; i++ {
+		assert(t, w.Write(&primitive{LongField: int64(i)}), nil)
+	}
+	assert(t, w.Close(), nil)
+	assert(t, len(completed), 3)
+	for i, file := range completed {
+		assert(t, file.Path, filepath.Join(dir, fmt.Sprintf("count-%03d.avro", i)))
+		assert(t, records(file.Path), file.Records)
+		info, err := os.Stat(file.Path)
+		assert(t, err, nil)
+		assert(t, file.Bytes, info.Size())
+	}
+	assert(t, completed[2].Records, int64(5))
+	assert(t, w.Write(&primitive{}), errRollingWriterClosed)
+
+	// by size, rotating only between blocks
+	completed = nil
+	w = newWriter("size", RollingDataFileWriterOptions{MaxBytes: 2000, File: DataFileWriterOptions{SyncRecords: 10}})
+	for i := 0; i < 100; i++ {
+		assert(t, w.Write(&primitive{StringField: "a string of some length to fill the file"}), nil)
+	}
+	assert(t, w.Close(), nil)
+	total := int64(0)
+	for _, file := range completed {
+		assert(t, file.Records%10, int64(0))
+		total += records(file.Path)
+	}
+	assert(t, len(completed) > 1, true)
+	assert(t, total, int64(100))
+
+	// by age, also without any further writes
+	completed = nil
+	w = newWriter("age", RollingDataFileWriterOptions{MaxAge: 50 * time.Millisecond})
+	assert(t, w.Write(&primitive{}), nil)
+	time.Sleep(200 * time.Millisecond)
+	assert(t, w.Write(&primitive{}), nil)
+	assert(t, w.Write(&primitive{}), nil)
+	assert(t, w.Close(), nil)
+	mu.Lock()
+	assert(t, len(completed), 2)
+	mu.Unlock()
+	assert(t, completed[0].Records, int64(1))
+	assert(t, completed[1].Records, int64(2))
+
+	// existing files are not overwritten
+	w = newWriter("count", RollingDataFileWriterOptions{})
+	if w.Write(&primitive{}) == nil {
+		t.Fatal("expected an error for an existing file")
+	}
+}
+
+func TestRollingDataFileWriter_onComplete(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	dir, err := ioutil.TempDir("", "rolling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the callback may use the writer, e.g. to start every file with a marker
+	var w *RollingDataFileWriter
+	var paths []string
+	w, err = NewRollingDataFileWriter(schema, NewDatumWriter(schema), RollingDataFileWriterOptions{
+		NextFile: func(seq int, opened time.Time) string {
+			return filepath.Join(dir, fmt.Sprintf("%03d.avro", seq))
+		},
+		MaxRecords: 3,
+		OnComplete: func(file CompletedFile) {
+			paths = append(paths, file.Path)
+			assert(t, w.Flush(), nil)
+			if len(paths) < 3 {
+				assert(t, w.Write(&primitive{LongField: -1}), nil)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 6; i++ {
+			assert(t, w.Write(&primitive{LongField: int64(i)}), nil)
+		}
+		assert(t, w.Rotate(), nil)
+		assert(t, w.Close(), nil)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnComplete deadlocked calling the writer")
+	}
+	assert(t, len(paths), 3)
+	dfr, err := NewDataFileReader(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dfr.Close()
+	var p primitive
+	assert(t, dfr.Next(&p), nil)
+	assert(t, p.LongField, int64(-1))
+}
+
+// failingCodec fails to compress as many times as it's told to.
+type failingCodec struct {
+	failures *int32
+}
+
+func (c failingCodec) Compress(dst, src []byte) ([]byte, error) {
+	if atomic.AddInt32(c.failures, -1) >= 0 {
+		return dst, fmt.Errorf("failingCodec: Cannot compress")
+	}
+	return append(dst, src...), nil
+}
+
+func (c failingCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+func TestRollingDataFileWriter_ageError(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	dir, err := ioutil.TempDir("", "rolling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	failures := int32(1)
+	RegisterCodec("test-fail", failingCodec{&failures})
+	defer func() {
+		codecsLock.Lock()
+		delete(codecs, "test-fail")
+		codecsLock.Unlock()
+	}()
+
+	var records []int64
+	w, err := NewRollingDataFileWriter(schema, NewDatumWriter(schema), RollingDataFileWriterOptions{
+		NextFile: func(seq int, opened time.Time) string {
+			return filepath.Join(dir, fmt.Sprintf("%03d.avro", seq))
+		},
+		File:   DataFileWriterOptions{Codec: "test-fail"},
+		MaxAge: 50 * time.Millisecond,
+		OnComplete: func(file CompletedFile) {
+			records = append(records, file.Records)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, w.Write(&primitive{}), nil)
+	time.Sleep(200 * time.Millisecond)
+
+	// the error of the rotation is returned once, then a new file is written
+	if w.Write(&primitive{}) == nil {
+		t.Fatal("expected the error of the rotation by age")
+	}
+	assert(t, w.Write(&primitive{}), nil)
+	assert(t, w.Flush(), nil)
+	assert(t, w.Close(), nil)
+	assert(t, records, []int64{1})
+}
@@ -2,8 +2,13 @@ package avro
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
+	"os"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestDataFileWriter(t *testing.T) {
@@ -47,7 +52,7 @@ func TestDataFileWriter(t *testing.T) {
 	assert(t, len(encoded), 1145)
 
 	// now make sure we can decode again
-	dfr, err := newDataFileReader(bytes.NewReader(encoded))
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(encoded), DataFileReaderOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -103,3 +108,1005 @@ func testComplex7(t *testing.T, reader *DataFileReader) {
 	assert(t, reader.Err(), nil)
 	assert(t, reader.err, io.EOF) // underlying error is EOF
 }
+
+func TestDataFileWriter_codecs(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	for _, codec := range []string{"null", "deflate", "snappy", "zstandard", "bzip2", "xz"} {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			assert(t, dfw.Write(&primitive{LongField: int64(i), StringField: "repeated string"}), nil)
+			if i%30 == 0 {
+				assert(t, dfw.Flush(), nil)
+			}
+		}
+		assert(t, dfw.Close(), nil)
+
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", codec, err)
+		}
+		assert(t, string(dfr.header.Meta[codecKey]), codec)
+		n := int64(0)
+		for dfr.HasNext() {
+			var p primitive
+			if err := dfr.Next(&p); err != nil {
+				t.Fatalf("%s: %v", codec, err)
+			}
+			assert(t, p.LongField, n)
+			assert(t, p.StringField, "repeated string")
+			n++
+		}
+		assert(t, dfr.Err(), nil)
+		assert(t, n, int64(100))
+	}
+}
+
+func TestDataFileWriter_unknownCodec(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	_, err := NewDataFileWriterWithOptions(&bytes.Buffer{}, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "lzo"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown codec")
+	}
+}
+
+// xorCodec is a toy codec used to test codec registration.
+type xorCodec struct{}
+
+func (xorCodec) Compress(dst, src []byte) ([]byte, error) {
+	for _, b := range src {
+		dst = append(dst, b^0x5a)
+	}
+	return dst, nil
+}
+
+func (c xorCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return c.Compress(dst, src)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	options := DataFileWriterOptions{Codec: "test-xor"}
+	if _, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options); err == nil {
+		t.Fatal("expected an error for a codec which is not registered yet")
+	}
+
+	RegisterCodec("test-xor", xorCodec{})
+	defer func() {
+		codecsLock.Lock()
+		delete(codecs, "test-xor")
+		codecsLock.Unlock()
+	}()
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, dfw.Write(&primitive{StringField: "xor"}), nil)
+	assert(t, dfw.Close(), nil)
+
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p primitive
+	assert(t, dfr.Next(&p), nil)
+	assert(t, p.StringField, "xor")
+}
+
+func TestDataFileWriter_syncAndMeta(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	write := func(options DataFileWriterOptions) []byte {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, dfw.Write(&primitive{}), nil)
+		assert(t, dfw.Close(), nil)
+		return buf.Bytes()
+	}
+	header := func(encoded []byte) *objFileHeader {
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dfr.header
+	}
+
+	// random sync markers differ between files
+	h1, h2 := header(write(DataFileWriterOptions{})), header(write(DataFileWriterOptions{}))
+	assert(t, len(h1.Sync), containerSyncSize)
+	assert(t, bytes.Equal(h1.Sync, h2.Sync), false)
+
+	// a deterministic sync marker makes the output reproducible
+	options := DataFileWriterOptions{
+		SyncMarker: []byte("0123456789abcdef"),
+		Meta:       map[string][]byte{"producer.version": []byte("1.2.3")},
+	}
+	f1, f2 := write(options), write(options)
+	assert(t, f1, f2)
+	h := header(f1)
+	assert(t, h.Sync, []byte("0123456789abcdef"))
+	assert(t, h.Meta["producer.version"], []byte("1.2.3"))
+
+	_, err := NewDataFileWriterWithOptions(&bytes.Buffer{}, schema, NewDatumWriter(schema), DataFileWriterOptions{SyncMarker: []byte("short")})
+	if err == nil {
+		t.Fatal("expected an error for a sync marker of the wrong size")
+	}
+	_, err = NewDataFileWriterWithOptions(&bytes.Buffer{}, schema, NewDatumWriter(schema), DataFileWriterOptions{Meta: map[string][]byte{"avro.custom": nil}})
+	if err == nil {
+		t.Fatal("expected an error for a reserved metadata key")
+	}
+}
+
+func TestDataFileReader_header(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{
+		Codec:      "deflate",
+		SyncMarker: []byte("0123456789abcdef"),
+		Meta:       map[string][]byte{"producer.version": []byte("1.2.3")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, dfw.Close(), nil)
+
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, dfr.Schema().String(), schema.String())
+	assert(t, dfr.Codec(), "deflate")
+	assert(t, dfr.SyncMarker(), []byte("0123456789abcdef"))
+	assert(t, dfr.MetaKeys(), []string{"avro.codec", "avro.schema", "producer.version"})
+	version, ok := dfr.Meta("producer.version")
+	assert(t, ok, true)
+	assert(t, version, []byte("1.2.3"))
+	_, ok = dfr.Meta("missing")
+	assert(t, ok, false)
+
+	r, err := NewDataFileReader("test/complex7.null.avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	assert(t, r.Codec(), "null")
+	assert(t, r.Schema().Type(), Record)
+}
+
+func TestDataFileReader_inputs(t *testing.T) {
+	encoded, err := ioutil.ReadFile("test/complex7.deflate.avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DataFileReaderOptions{BufferSize: 16}
+
+	r, err := NewDataFileStreamReader(iotest.OneByteReader(bytes.NewReader(encoded)), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testComplex7(t, r)
+
+	r, err = NewDataFileSeekReader(bytes.NewReader(encoded), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testComplex7(t, r)
+
+	r, err = NewDataFileReaderAt(bytes.NewReader(encoded), int64(len(encoded)), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testComplex7(t, r)
+}
+
+func TestDataFileReader_noBlocks(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	if _, err := NewDataFileWriter(buf, schema, NewDatumWriter(schema)); err != nil {
+		t.Fatal(err)
+	}
+	// only the header was written
+	r, err := NewDataFileStreamReader(buf, DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, r.HasNext(), false)
+	assert(t, r.Err(), nil)
+}
+
+func TestDataFileReader_readerSchema(t *testing.T) {
+	writerSchema := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "removed", "type": "string"},
+		{"name": "name", "type": "string"}
+	]}`)
+	readerSchema := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "added", "type": "string", "default": "none"}
+	]}`)
+	type Event struct {
+		Id    int64
+		Name  string
+		Added string
+	}
+
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriter(buf, writerSchema, NewDatumWriter(writerSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int32(0); i < 3; i++ {
+		record := NewGenericRecord(writerSchema)
+		record.Set("id", i)
+		record.Set("removed", "gone")
+		record.Set("name", "event")
+		assert(t, dfw.Write(record), nil)
+	}
+	assert(t, dfw.Close(), nil)
+
+	options := DataFileReaderOptions{ReaderSchema: readerSchema}
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, dfr.Schema(), writerSchema)
+	for i := int64(0); i < 3; i++ {
+		var e Event
+		assert(t, dfr.HasNext(), true)
+		assert(t, dfr.Next(&e), nil)
+		assert(t, e, Event{Id: i, Name: "event", Added: "none"})
+	}
+	assert(t, dfr.HasNext(), false)
+
+	dfr, err = NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record *GenericRecord
+	assert(t, dfr.Next(&record), nil)
+	assert(t, record.Get("id"), int64(0))
+	assert(t, record.Get("added"), "none")
+	assert(t, record.Get("removed"), nil)
+
+	incompatible := MustParseSchema(`{"type": "record", "name": "Event", "fields": [{"name": "id", "type": "boolean"}]}`)
+	_, err = NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{ReaderSchema: incompatible})
+	if err == nil {
+		t.Fatal("expected an error for an incompatible reader schema")
+	}
+}
+
+func TestDataFileReader_split(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "deflate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 1000
+	for i := 0; i < total; i++ {
+		assert(t, dfw.Write(&primitive{LongField: int64(i)}), nil)
+		if i%37 == 0 {
+			assert(t, dfw.Flush(), nil)
+		}
+	}
+	assert(t, dfw.Close(), nil)
+	encoded := buf.Bytes()
+	input := bytes.NewReader(encoded)
+	size := int64(len(encoded))
+
+	for _, splits := range []int64{1, 3, 16, size} {
+		results := make([][]int64, splits)
+		errs := make(chan error, splits)
+		for s := int64(0); s < splits; s++ {
+			go func(s int64) {
+				start, end := size*s/splits, size*(s+1)/splits
+				r, err := NewDataFileReaderAt(input, size, DataFileReaderOptions{BufferSize: 16, ReadAhead: int(s % 3)})
+				if err == nil {
+					err = r.Sync(start)
+				}
+				for err == nil && r.HasNext() && !r.PastSync(end) {
+					var p primitive
+					if err = r.Next(&p); err == nil {
+						results[s] = append(results[s], p.LongField)
+					}
+				}
+				if err == nil {
+					err = r.Err()
+				}
+				errs <- err
+			}(s)
+		}
+		for s := int64(0); s < splits; s++ {
+			assert(t, <-errs, nil)
+		}
+		n := int64(0)
+		for _, values := range results {
+			for _, value := range values {
+				assert(t, value, n)
+				n++
+			}
+		}
+		assert(t, n, int64(total))
+	}
+
+	// a block can be read again from its sync point
+	r, err := NewDataFileSeekReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p primitive
+	for i := 0; i < 40; i++ {
+		assert(t, r.Next(&p), nil)
+	}
+	block, next := r.PreviousSync(), r.Tell()
+	assert(t, block < next, true)
+	assert(t, r.SeekBlock(block), nil)
+	assert(t, r.Next(&p), nil)
+	assert(t, p.LongField, int64(38))
+	assert(t, r.Sync(block), nil)
+	assert(t, r.PreviousSync(), next)
+	assert(t, r.Sync(size), nil)
+	assert(t, r.HasNext(), false)
+	assert(t, r.Err(), nil)
+
+	r, err = NewDataFileStreamReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, r.Next(&p), nil)
+	assert(t, r.Sync(0), ErrNotSeekable)
+	assert(t, r.SeekBlock(0), ErrNotSeekable)
+	_, err = r.BuildIndex()
+	assert(t, err, ErrNotSeekable)
+	// the reader carries on where it was
+	assert(t, r.Err(), nil)
+	n := int64(1)
+	for ; r.HasNext(); n++ {
+		assert(t, r.Next(&p), nil)
+		assert(t, p.LongField, n)
+	}
+	assert(t, n, int64(total))
+}
+
+func TestDataFileReader_readAhead(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "deflate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 1000
+	for i := 0; i < total; i++ {
+		assert(t, dfw.Write(&primitive{LongField: int64(i)}), nil)
+		if i%10 == 0 {
+			assert(t, dfw.Flush(), nil)
+		}
+	}
+	assert(t, dfw.Close(), nil)
+	encoded := buf.Bytes()
+	options := DataFileReaderOptions{ReadAhead: 4}
+
+	r, err := NewDataFileSeekReader(bytes.NewReader(encoded), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p primitive
+	for i := int64(0); r.HasNext(); i++ {
+		assert(t, r.Next(&p), nil)
+		assert(t, p.LongField, i)
+		if i == 500 {
+			// seeking stops the read ahead and restarts it from the new position
+			block := r.PreviousSync()
+			assert(t, r.SeekBlock(block), nil)
+			assert(t, r.Next(&p), nil)
+			assert(t, p.LongField, int64(491))
+			for j := int64(492); j <= i; j++ {
+				assert(t, r.Next(&p), nil)
+			}
+		}
+	}
+	assert(t, p.LongField, int64(total-1))
+	assert(t, r.Err(), nil)
+	assert(t, r.Close(), nil)
+
+	// closing with blocks pending
+	r, err = NewDataFileStreamReader(bytes.NewReader(encoded), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, r.Next(&p), nil)
+	assert(t, r.Close(), nil)
+	assert(t, r.HasNext(), false)
+
+	// closing while the read ahead is blocked on an input which can't be closed
+	r, err = NewDataFileSeekReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, r.Next(&p), nil)
+	pr, pw := io.Pipe()
+	go pw.Write(encoded[:r.Tell()]) // only the first block
+	r, err = NewDataFileStreamReader(struct{ io.Reader }{pr}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, r.Next(&p), nil)
+	closed := make(chan error)
+	go func() { closed <- r.Close() }()
+	select {
+	case err := <-closed:
+		assert(t, err, nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on the read ahead")
+	}
+	assert(t, r.HasNext(), false)
+	pw.CloseWithError(io.ErrClosedPipe)
+
+	// a corrupted block stops reading with an error
+	corrupted := append([]byte(nil), encoded...)
+	copy(corrupted[len(corrupted)/2:], "garbage garbage garbage")
+	r, err = NewDataFileStreamReader(bytes.NewReader(corrupted), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ; r.HasNext(); n++ {
+		assert(t, r.Next(&p), nil)
+	}
+	assert(t, n > 0 && n < total, true)
+	if r.Err() == nil {
+		t.Fatal("expected an error for a corrupted block")
+	}
+	assert(t, r.Close(), nil)
+}
+
+// failingWriter fails every write once limit bytes have been written.
+type failingWriter struct {
+	limit int
+	err   error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.limit -= len(p); w.limit < 0 {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func TestDataFileWriter_concurrency(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	options := DataFileWriterOptions{Codec: "deflate", Concurrency: 4}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const producers, perProducer = 8, 500
+	errs := make(chan error, producers)
+	for g := 0; g < producers; g++ {
+		go func(g int) {
+			for i := 0; i < perProducer; i++ {
+				err := dfw.Write(&primitive{IntField: int32(g), LongField: int64(i)})
+				if err == nil && i%50 == 0 {
+					err = dfw.Flush()
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(g)
+	}
+	for g := 0; g < producers; g++ {
+		assert(t, <-errs, nil)
+	}
+	assert(t, dfw.Close(), nil)
+
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := make([]int64, producers)
+	for dfr.HasNext() {
+		var p primitive
+		assert(t, dfr.Next(&p), nil)
+		// records of each producer keep their order
+		assert(t, p.LongField, next[p.IntField])
+		next[p.IntField]++
+	}
+	assert(t, dfr.Err(), nil)
+	for g := 0; g < producers; g++ {
+		assert(t, next[g], int64(perProducer))
+	}
+
+	// the first error of a background write is returned
+	output := &failingWriter{limit: 1000, err: errors.New("disk full")}
+	dfw, err = NewDataFileWriterWithOptions(output, schema, NewDatumWriter(schema), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && err == nil; i++ {
+		if err = dfw.Write(&primitive{StringField: "some string to fill the blocks"}); err == nil {
+			err = dfw.Flush()
+		}
+	}
+	if closeErr := dfw.Close(); err == nil {
+		err = closeErr
+	}
+	assert(t, err, output.err)
+	assert(t, dfw.Close(), output.err)
+}
+
+func TestDataFileWriter_writeErrors(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	output := &failingWriter{limit: 10, err: errors.New("disk full")}
+	_, err := NewDataFileWriter(output, schema, NewDatumWriter(schema))
+	assert(t, err, output.err)
+
+	// the header fits, the first block doesn't
+	output = &failingWriter{limit: 1000, err: errors.New("disk full")}
+	dfw, err := NewDataFileWriter(output, schema, NewDatumWriter(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		assert(t, dfw.Write(&primitive{StringField: "some string to fill the block"}), nil)
+	}
+	assert(t, dfw.Flush(), output.err)
+	assert(t, dfw.Close(), output.err)
+}
+
+func TestDataFileWriter_syncInterval(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	// blockSizes reads back the number of records in each block
+	blockSizes := func(encoded []byte) (sizes []int64) {
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for dfr.block != nil {
+			sizes = append(sizes, dfr.block.NumEntries)
+			dfr.NextBlock()
+		}
+		assert(t, dfr.Err(), nil)
+		return
+	}
+
+	for _, concurrency := range []int{0, 2} {
+		buf := &bytes.Buffer{}
+		options := DataFileWriterOptions{SyncRecords: 10, SyncInterval: -1, Concurrency: concurrency}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets := make(map[int64]int64)
+		for i := int64(0); i < 25; i++ {
+			if i%12 == 0 {
+				offset, err := dfw.Sync()
+				assert(t, err, nil)
+				offsets[i] = offset
+			}
+			assert(t, dfw.Write(&primitive{LongField: i}), nil)
+		}
+		assert(t, dfw.Close(), nil)
+		assert(t, blockSizes(buf.Bytes()), []int64{10, 2, 10, 2, 1, 0})
+
+		// sync offsets point at the block starting with the next record
+		dfr, err := NewDataFileSeekReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, offset := range offsets {
+			var p primitive
+			assert(t, dfr.SeekBlock(offset), nil)
+			assert(t, dfr.Next(&p), nil)
+			assert(t, p.LongField, i)
+		}
+	}
+
+	// blocks are flushed once they reach the sync interval
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriter(buf, schema, NewDatumWriter(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		assert(t, dfw.Write(&primitive{StringField: string(make([]byte, 1000))}), nil)
+	}
+	assert(t, dfw.Close(), nil)
+	sizes := blockSizes(buf.Bytes())
+	assert(t, len(sizes) > 10, true)
+	assert(t, sizes[0] < 100, true)
+}
+
+func TestOpenDataFileWriterForAppend(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	f, err := ioutil.TempFile("", "append*.avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	dfw, err := NewDataFileWriterWithOptions(f, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "snappy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 10; i++ {
+		assert(t, dfw.Write(&primitive{LongField: i}), nil)
+	}
+	assert(t, dfw.Close(), nil)
+
+	// the codec and sync marker are taken from the file
+	for _, appendSchema := range []Schema{schema, nil} {
+		dfw, err = OpenDataFileWriterForAppend(f, appendSchema, NewDatumWriter(schema), DataFileWriterOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := int64(0); i < 10; i++ {
+			assert(t, dfw.Write(&primitive{LongField: i}), nil)
+		}
+		assert(t, dfw.Close(), nil)
+	}
+
+	dfr, err := NewDataFileReader(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dfr.Close()
+	assert(t, dfr.Codec(), "snappy")
+	n := int64(0)
+	for ; dfr.HasNext(); n++ {
+		var p primitive
+		assert(t, dfr.Next(&p), nil)
+		assert(t, p.LongField, n%10)
+	}
+	assert(t, dfr.Err(), nil)
+	assert(t, n, int64(30))
+
+	other := MustParseSchema(`{"type": "record", "name": "Other", "fields": [{"name": "id", "type": "int"}]}`)
+	if _, err = OpenDataFileWriterForAppend(f, other, NewDatumWriter(other), DataFileWriterOptions{}); err == nil {
+		t.Fatal("expected an error for a different schema")
+	}
+	if _, err = OpenDataFileWriterForAppend(f, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "deflate"}); err == nil {
+		t.Fatal("expected an error for a different codec")
+	}
+}
+
+func TestOpenDataFileWriterForAppend_blocks(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	for _, codec := range []string{"null", "deflate", "snappy", "zstandard", "bzip2", "xz"} {
+		f, err := ioutil.TempFile("", "append*.avro")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		dfw, err := NewDataFileWriterWithOptions(f, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				if dfw, err = OpenDataFileWriterForAppend(f, schema, NewDatumWriter(schema), DataFileWriterOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for j := int64(0); j < 10; j++ {
+				assert(t, dfw.Write(&primitive{LongField: j}), nil)
+			}
+			assert(t, dfw.Close(), nil)
+		}
+		size, err := f.Seek(0, io.SeekEnd)
+		assert(t, err, nil)
+
+		// appending nothing leaves the file as it is
+		dfw, err = OpenDataFileWriterForAppend(f, schema, NewDatumWriter(schema), DataFileWriterOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, dfw.Close(), nil)
+		end, err := f.Seek(0, io.SeekEnd)
+		assert(t, err, nil)
+		assert(t, end, size)
+
+		// only the last block is empty
+		dfr, err := NewDataFileReader(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts []int64
+		for dfr.block != nil {
+			counts = append(counts, dfr.block.NumEntries)
+			dfr.NextBlock()
+		}
+		assert(t, dfr.Err(), nil)
+		assert(t, counts, []int64{10, 10, 10, 0})
+		dfr.Close()
+	}
+}
+
+func TestDataFileWriter_rawBlocks(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	write := func(codec string, from, to int64) []byte {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec, SyncRecords: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := from; i < to; i++ {
+			assert(t, dfw.Write(&primitive{LongField: i}), nil)
+		}
+		assert(t, dfw.Close(), nil)
+		return buf.Bytes()
+	}
+	inputs := [][]byte{write("deflate", 0, 50), write("snappy", 50, 100)}
+
+	for _, concurrency := range []int{0, 2} {
+		for _, codec := range []string{"deflate", "xz"} {
+			buf := &bytes.Buffer{}
+			options := DataFileWriterOptions{Codec: codec, Concurrency: concurrency}
+			dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, input := range inputs {
+				dfr, err := NewDataFileStreamReader(bytes.NewReader(input), DataFileReaderOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for {
+					block, err := dfr.NextRawBlock()
+					if err == io.EOF {
+						break
+					}
+					assert(t, err, nil)
+					assert(t, block.Codec, dfr.Codec())
+					assert(t, dfw.WriteRawBlock(block), nil)
+				}
+			}
+			assert(t, dfw.Close(), nil)
+
+			dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := int64(0)
+			for ; dfr.HasNext(); n++ {
+				var p primitive
+				assert(t, dfr.Next(&p), nil)
+				assert(t, p.LongField, n)
+			}
+			assert(t, dfr.Err(), nil)
+			assert(t, n, int64(100))
+		}
+	}
+
+	// reading records and raw blocks can be mixed
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(inputs[0]), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p primitive
+	assert(t, dfr.Next(&p), nil)
+	block, err := dfr.NextRawBlock()
+	assert(t, err, nil)
+	assert(t, block.Count, int64(7))
+	assert(t, dfr.Next(&p), nil)
+	assert(t, p.LongField, int64(7))
+}
+
+func TestDataFileReader_skipCorrupted(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "snappy", SyncRecords: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 100; i++ {
+		assert(t, dfw.Write(&primitive{LongField: i, StringField: "some text"}), nil)
+	}
+	assert(t, dfw.Close(), nil)
+	encoded := buf.Bytes()
+
+	// find the offsets of the blocks
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts, ends []int64
+	for dfr.block != nil {
+		starts, ends = append(starts, dfr.PreviousSync()), append(ends, dfr.Tell())
+		dfr.NextBlock()
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		read    int64
+		ranges  []SkippedRange
+	}{
+		{"sync marker", func(b []byte) []byte {
+			b[ends[3]-1]++
+			return b
+		}, 80, []SkippedRange{{Start: starts[3], End: ends[4], Records: 10}}},
+		{"block data", func(b []byte) []byte {
+			b[(starts[6]+ends[6])/2]++
+			return b
+		}, 90, []SkippedRange{{Start: starts[6], End: ends[6], Records: 10}}},
+		{"truncated", func(b []byte) []byte {
+			return b[:len(b)-5]
+		}, 100, []SkippedRange{{Start: starts[10], End: int64(len(encoded) - 5)}}},
+	}
+	for _, test := range tests {
+		corrupted := test.corrupt(append([]byte(nil), encoded...))
+		for _, seekable := range []bool{false, true} {
+			for _, readAhead := range []int{0, 3} {
+				var ranges []SkippedRange
+				options := DataFileReaderOptions{ReadAhead: readAhead, SkipCorrupted: true, OnSkip: func(r SkippedRange) {
+					if r.Err == nil {
+						t.Errorf("%s: skipped range without an error", test.name)
+					}
+					r.Err = nil
+					ranges = append(ranges, r)
+				}}
+				var dfr *DataFileReader
+				if seekable {
+					dfr, err = NewDataFileSeekReader(bytes.NewReader(corrupted), options)
+				} else {
+					dfr, err = NewDataFileStreamReader(bytes.NewReader(corrupted), options)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				n := int64(0)
+				for ; dfr.HasNext(); n++ {
+					var p primitive
+					assert(t, dfr.Next(&p), nil)
+				}
+				assert(t, dfr.Err(), nil)
+				assert(t, n, test.read)
+				assert(t, ranges, test.ranges)
+				assert(t, dfr.Skipped(), SkipStats{Ranges: 1, Bytes: test.ranges[0].End - test.ranges[0].Start, Records: test.ranges[0].Records})
+			}
+		}
+	}
+}
+
+func TestDataFileReader_concatenated(t *testing.T) {
+	schema1 := MustParseSchema(primitiveSchemaRaw)
+	schema2 := MustParseSchema(`{"type": "record", "name": "Other", "fields": [{"name": "id", "type": "long"}]}`)
+	write := func(schema Schema, codec string, ids ...int64) []byte {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec, SyncRecords: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if schema == schema1 {
+				assert(t, dfw.Write(&primitive{LongField: id}), nil)
+			} else {
+				record := NewGenericRecord(schema)
+				record.Set("id", id)
+				assert(t, dfw.Write(record), nil)
+			}
+		}
+		assert(t, dfw.Close(), nil)
+		return buf.Bytes()
+	}
+	var input []byte
+	input = append(input, write(schema1, "deflate", 0, 1, 2)...)
+	input = append(input, write(schema1, "snappy", 3, 4)...)
+	input = append(input, write(schema2, "null")...)
+	input = append(input, write(schema2, "null", 5, 6, 7)...)
+
+	for _, readAhead := range []int{0, 2} {
+		options := DataFileReaderOptions{Concatenated: true, ReadAhead: readAhead}
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(input), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		var codecs []string
+		changes := 0
+		for dfr.HasNext() {
+			var record *GenericRecord
+			assert(t, dfr.Next(&record), nil)
+			if dfr.SchemaChanged() {
+				assert(t, dfr.Schema().GetName(), "Other")
+				assert(t, len(ids), 5)
+				changes++
+			}
+			if dfr.Schema().GetName() == "Other" {
+				ids = append(ids, record.Get("id").(int64))
+			} else {
+				ids = append(ids, record.Get("longField").(int64))
+			}
+			codecs = append(codecs, dfr.Codec())
+		}
+		assert(t, dfr.Err(), nil)
+		assert(t, ids, []int64{0, 1, 2, 3, 4, 5, 6, 7})
+		assert(t, changes, 1)
+		assert(t, codecs, []string{"deflate", "deflate", "deflate", "snappy", "snappy", "null", "null", "null"})
+	}
+
+	// without the option the second header is an error
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(input), DataFileReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dfr.HasNext() {
+		var record *GenericRecord
+		assert(t, dfr.Next(&record), nil)
+	}
+	if dfr.Err() == nil {
+		t.Fatal("expected an error for a concatenated file")
+	}
+}
+
+func TestDataFileReader_seekRecord(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	for _, concurrency := range []int{0, 2} {
+		buf := &bytes.Buffer{}
+		options := DataFileWriterOptions{Codec: "deflate", SyncRecords: 7, BuildIndex: true, Concurrency: concurrency}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := int64(0); i < 100; i++ {
+			assert(t, dfw.Write(&primitive{LongField: i}), nil)
+		}
+		assert(t, dfw.Close(), nil)
+		index := dfw.Index()
+		assert(t, len(index.Blocks), 15)
+		assert(t, index.Records(), int64(100))
+		encoded := buf.Bytes()
+
+		// the index survives a round trip and matches one built by the reader
+		stored := &bytes.Buffer{}
+		_, err = index.WriteTo(stored)
+		assert(t, err, nil)
+		stored.WriteString("trailer")
+		loaded, err := ReadBlockIndex(stored)
+		assert(t, err, nil)
+		assert(t, loaded, index)
+		// nothing past the index is consumed
+		assert(t, stored.String(), "trailer")
+		dfr, err := NewDataFileSeekReader(bytes.NewReader(encoded), DataFileReaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		built, err := dfr.BuildIndex()
+		assert(t, err, nil)
+		assert(t, built, index)
+
+		dfr, err = NewDataFileSeekReader(bytes.NewReader(encoded), DataFileReaderOptions{Index: loaded})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int64{99, 0, 42, 6, 7, 8, 63} {
+			var p primitive
+			assert(t, dfr.SeekRecord(n), nil)
+			assert(t, dfr.Next(&p), nil)
+			assert(t, p.LongField, n)
+		}
+		if dfr.SeekRecord(100) == nil {
+			t.Fatal("expected an error for a record out of range")
+		}
+	}
+
+	other := &BlockIndex{SyncMarker: make([]byte, containerSyncSize)}
+	_, err := NewDataFileReaderWithOptions("test/complex7.null.avro", DataFileReaderOptions{Index: other})
+	if err == nil {
+		t.Fatal("expected an error for the index of another file")
+	}
+}
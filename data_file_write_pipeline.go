@@ -0,0 +1,155 @@
+package avro
+
+import (
+	"bytes"
+	"sync"
+)
+
+// blockWritePipeline compresses and writes the blocks of a DataFileWriter
+// in the background. A pool of workers compresses blocks as they are
+// flushed and a single goroutine writes them to the output in the order
+// they were flushed. As pending is bounded, so is the number of blocks in
+// memory.
+type blockWritePipeline struct {
+	pending  chan *flushJob // in flush order, consumed by the output goroutine
+	work     chan *flushJob // consumed by the workers
+	finished chan struct{}  // closed when the output goroutine exits
+	workers  sync.WaitGroup
+	buffers  sync.Pool
+	closed   bool
+	last     *flushJob // the most recently flushed block
+
+	errLock  sync.Mutex
+	firstErr error
+}
+
+type flushJob struct {
+	count      int64
+	data       *bytes.Buffer
+	compressed []byte
+	err        error
+	done       chan struct{} // closed once compressed or err is set
+	written    chan struct{} // closed once the block has been written or dropped
+}
+
+func newBlockWritePipeline(w *DataFileWriter, concurrency int) *blockWritePipeline {
+	p := &blockWritePipeline{
+		pending:  make(chan *flushJob, concurrency),
+		work:     make(chan *flushJob, concurrency),
+		finished: make(chan struct{}),
+	}
+	p.buffers.New = func() interface{} { return &bytes.Buffer{} }
+	p.workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go p.compress(w.codec)
+	}
+	go p.write(w)
+	return p
+}
+
+func (p *blockWritePipeline) compress(codec Codec) {
+	defer p.workers.Done()
+	for job := range p.work {
+		// there is no point compressing blocks which will not be written
+		if job.err = p.err(); job.err == nil {
+			job.compressed, job.err = codec.Compress(nil, job.data.Bytes())
+		}
+		close(job.done)
+	}
+}
+
+// write owns the output of the writer until the pipeline is closed.
+func (p *blockWritePipeline) write(w *DataFileWriter) {
+	defer close(p.finished)
+	for job := range p.pending {
+		<-job.done
+		err := job.err
+		if err == nil && p.err() == nil {
+			err = w.writeBlock(job.count, job.compressed)
+		}
+		if err != nil {
+			p.fail(err)
+		}
+		if job.data != nil {
+			job.data.Reset()
+			p.buffers.Put(job.data)
+		}
+		close(job.written)
+	}
+}
+
+// flush hands the current block of the writer over to the pipeline and
+// gives the writer a fresh one.
+func (p *blockWritePipeline) flush(w *DataFileWriter) error {
+	job := &flushJob{
+		count:   w.blockCount,
+		data:    w.blockBuf,
+		done:    make(chan struct{}),
+		written: make(chan struct{}),
+	}
+	w.blockBuf = p.buffers.Get().(*bytes.Buffer)
+	w.blockEnc = newBinaryEncoder(w.blockBuf)
+	w.blockCount = 0
+
+	p.pending <- job
+	p.work <- job
+	p.last = job
+	return p.err()
+}
+
+// writeCompressed queues a block which needs no compression after the
+// blocks flushed so far.
+func (p *blockWritePipeline) writeCompressed(count int64, compressed []byte) error {
+	job := &flushJob{
+		count:      count,
+		compressed: append([]byte(nil), compressed...),
+		done:       make(chan struct{}),
+		written:    make(chan struct{}),
+	}
+	close(job.done)
+	p.pending <- job
+	p.last = job
+	return p.err()
+}
+
+// wait blocks until every block flushed so far has been written.
+func (p *blockWritePipeline) wait() error {
+	if p.last != nil {
+		<-p.last.written
+	}
+	return p.err()
+}
+
+// close waits for all pending blocks and stops the goroutines.
+func (p *blockWritePipeline) close() error {
+	if p.closed {
+		return p.err()
+	}
+	p.closed = true
+	close(p.pending)
+	close(p.work)
+	<-p.finished
+	p.workers.Wait()
+	return p.err()
+}
+
+func (p *blockWritePipeline) fail(err error) {
+	p.errLock.Lock()
+	if p.firstErr == nil {
+		p.firstErr = err
+	}
+	p.errLock.Unlock()
+}
+
+func (p *blockWritePipeline) err() error {
+	p.errLock.Lock()
+	defer p.errLock.Unlock()
+	return p.firstErr
+}
+
+func (w *DataFileWriter) pipelineErr() error {
+	if w.pipeline != nil {
+		return w.pipeline.err()
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package avro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// DatasetOptions configures a DatasetReader.
+type DatasetOptions struct {
+	// ReaderSchema is the schema records of all files are read as, each
+	// file's writer schema is resolved to it with a DatumProjector. If nil,
+	// the writer schema of the first file is used as the reader schema.
+	ReaderSchema Schema
+
+	// Shards splits the files between this many readers, e.g. one per
+	// worker, and Shard selects the one to read, from 0 to Shards-1. Files
+	// are assigned to shards in turns, in the order of their names.
+	Shard, Shards int
+
+	// File configures the DataFileReader of each file, its ReaderSchema is
+	// replaced with the one of the dataset.
+	File DataFileReaderOptions
+}
+
+// DatasetReader reads the records of many object container files, e.g. the
+// part files of a directory, as one stream of records of a single schema.
+// Files are read one after the other in the order of their names.
+type DatasetReader struct {
+	files   []string
+	open    func(name string, options DataFileReaderOptions) (*DataFileReader, error)
+	options DatasetOptions
+
+	next    int // index of the next file to open
+	current *DataFileReader
+	err     error
+}
+
+// NewDatasetReader reads the files matching a filepath.Glob pattern, e.g.
+// "data/events/*.avro". Returns an error if no file matches.
+func NewDatasetReader(pattern string, options DatasetOptions) (*DatasetReader, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return newDatasetReader(files, func(name string, options DataFileReaderOptions) (*DataFileReader, error) {
+		return NewDataFileReaderWithOptions(name, options)
+	}, options)
+}
+
+func newDatasetReader(files []string, open func(string, DataFileReaderOptions) (*DataFileReader, error), options DatasetOptions) (*DatasetReader, error) {
+	if len(files) == 0 {
+		return nil, errors.New("DatasetReader: No files to read")
+	}
+	if options.Shards < 0 || options.Shard < 0 || (options.Shards > 0 && options.Shard >= options.Shards) {
+		return nil, fmt.Errorf("DatasetReader: Invalid shard %d of %d", options.Shard, options.Shards)
+	}
+	sort.Strings(files)
+	if options.Shards > 1 {
+		var shard []string
+		for i := options.Shard; i < len(files); i += options.Shards {
+			shard = append(shard, files[i])
+		}
+		files = shard
+	}
+	return &DatasetReader{files: files, open: open, options: options}, nil
+}
+
+// Files returns the names of the files read by this reader, i.e. the files
+// of its shard.
+func (reader *DatasetReader) Files() []string {
+	return append([]string(nil), reader.files...)
+}
+
+// File returns the name of the file records are currently read from.
+func (reader *DatasetReader) File() string {
+	if reader.next == 0 {
+		return ""
+	}
+	return reader.files[reader.next-1]
+}
+
+// Schema returns the schema records are read as, which is only known once
+// the first file has been opened if DatasetOptions.ReaderSchema is not set.
+func (reader *DatasetReader) Schema() Schema {
+	return reader.options.ReaderSchema
+}
+
+// HasNext tells if there are more records, opening the next files as
+// needed. Returns false on any error, see Err.
+func (reader *DatasetReader) HasNext() bool {
+	for reader.err == nil {
+		if reader.current != nil {
+			if reader.current.HasNext() {
+				return true
+			}
+			if err := reader.current.Err(); err != nil {
+				reader.err = fmt.Errorf("DatasetReader: %s: %s", reader.File(), err.Error())
+				return false
+			}
+			reader.err = reader.closeCurrent()
+			continue
+		}
+		if reader.next == len(reader.files) {
+			return false
+		}
+		reader.err = reader.openNext()
+	}
+	return false
+}
+
+// Next reads the next record into v, see DataFileReader.Next.
+// Returns io.EOF after the last record of the last file.
+func (reader *DatasetReader) Next(v interface{}) error {
+	if !reader.HasNext() {
+		if reader.err != nil {
+			return reader.err
+		}
+		return io.EOF
+	}
+	if err := reader.current.Next(v); err != nil {
+		reader.err = fmt.Errorf("DatasetReader: %s: %s", reader.File(), err.Error())
+		return reader.err
+	}
+	return nil
+}
+
+// Err returns the error which stopped reading, if any.
+func (reader *DatasetReader) Err() error {
+	return reader.err
+}
+
+// Close closes the file currently being read.
+func (reader *DatasetReader) Close() error {
+	reader.next = len(reader.files)
+	return reader.closeCurrent()
+}
+
+func (reader *DatasetReader) openNext() error {
+	name := reader.files[reader.next]
+	reader.next++
+	options := reader.options.File
+	options.ReaderSchema = reader.options.ReaderSchema
+	current, err := reader.open(name, options)
+	if err != nil {
+		return fmt.Errorf("DatasetReader: %s: %s", name, err.Error())
+	}
+	if reader.options.ReaderSchema == nil {
+		// the schema of the first file is the one of the whole dataset
+		reader.options.ReaderSchema = current.Schema()
+	}
+	reader.current = current
+	return nil
+}
+
+func (reader *DatasetReader) closeCurrent() error {
+	if reader.current == nil {
+		return nil
+	}
+	err := reader.current.Close()
+	reader.current = nil
+	return err
+}
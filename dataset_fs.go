@@ -0,0 +1,35 @@
+//go:build go1.16
+// +build go1.16
+
+package avro
+
+import (
+	"io"
+	"io/fs"
+)
+
+// NewDatasetReaderFS reads the files of a file system which match an
+// fs.Glob pattern, e.g. "events/*.avro". Files which implement io.Seeker
+// are read with seeking enabled. Returns an error if no file matches.
+func NewDatasetReaderFS(fsys fs.FS, pattern string, options DatasetOptions) (*DatasetReader, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return newDatasetReader(files, func(name string, options DataFileReaderOptions) (*DataFileReader, error) {
+		file, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		var reader *DataFileReader
+		if seeker, ok := file.(io.ReadSeeker); ok {
+			reader, err = NewDataFileSeekReader(seeker, options)
+		} else {
+			reader, err = NewDataFileStreamReader(file, options)
+		}
+		if err != nil {
+			file.Close()
+		}
+		return reader, err
+	}, options)
+}
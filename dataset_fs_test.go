@@ -0,0 +1,28 @@
+//go:build go1.16
+// +build go1.16
+
+package avro
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNewDatasetReaderFS(t *testing.T) {
+	dir := writeDataset(t)
+	defer os.RemoveAll(dir)
+	readerSchema := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"}
+	]}`)
+
+	reader, err := NewDatasetReaderFS(os.DirFS(dir), "part-*.avro", DatasetOptions{ReaderSchema: readerSchema, Shard: 1, Shards: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, reader.Files(), []string{"part-1.avro", "part-3.avro"})
+	ids := readDataset(t, reader)
+	assert(t, len(ids), 20)
+	assert(t, ids[0], int64(10))
+	assert(t, ids[19], int64(39))
+}
@@ -0,0 +1,159 @@
+package avro
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDataset writes 5 part files of 10 records each into a new directory,
+// the last two with a newer version of the schema.
+func writeDataset(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "name", "type": "string"}
+	]}`)
+	v2 := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "extra", "type": "string"}
+	]}`)
+	for part := 0; part < 5; part++ {
+		schema := v1
+		if part >= 3 {
+			schema = v2
+		}
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("part-%d.avro", part)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dfw, err := NewDataFileWriter(f, schema, NewDatumWriter(schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			record := NewGenericRecord(schema)
+			if schema == v1 {
+				record.Set("id", int32(part*10+i))
+			} else {
+				record.Set("id", int64(part*10+i))
+				record.Set("extra", "extra")
+			}
+			record.Set("name", "event")
+			assert(t, dfw.Write(record), nil)
+		}
+		assert(t, dfw.Close(), nil)
+		assert(t, f.Close(), nil)
+	}
+	return dir
+}
+
+type datasetEvent struct {
+	Id   int64
+	Name string
+}
+
+func readDataset(t *testing.T, reader *DatasetReader) (ids []int64) {
+	for reader.HasNext() {
+		var e datasetEvent
+		assert(t, reader.Next(&e), nil)
+		assert(t, e.Name, "event")
+		ids = append(ids, e.Id)
+	}
+	assert(t, reader.Err(), nil)
+	assert(t, reader.Close(), nil)
+	return
+}
+
+func TestDatasetReader(t *testing.T) {
+	dir := writeDataset(t)
+	defer os.RemoveAll(dir)
+	readerSchema := MustParseSchema(`{"type": "record", "name": "Event", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"}
+	]}`)
+
+	reader, err := NewDatasetReader(filepath.Join(dir, "*.avro"), DatasetOptions{ReaderSchema: readerSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := readDataset(t, reader)
+	assert(t, len(ids), 50)
+	for i, id := range ids {
+		assert(t, id, int64(i))
+	}
+
+	// shards cover every file exactly once
+	seen := make(map[int64]bool)
+	for shard := 0; shard < 3; shard++ {
+		reader, err := NewDatasetReader(filepath.Join(dir, "*.avro"), DatasetOptions{ReaderSchema: readerSchema, Shard: shard, Shards: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, len(reader.Files()), []int{2, 2, 1}[shard])
+		for _, id := range readDataset(t, reader) {
+			assert(t, seen[id], false)
+			seen[id] = true
+		}
+	}
+	assert(t, len(seen), 50)
+
+	// without a reader schema the schema of the first file is used,
+	// which can't read the newer files
+	reader, err = NewDatasetReader(filepath.Join(dir, "*.avro"), DatasetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ; reader.HasNext(); n++ {
+		var record *GenericRecord
+		assert(t, reader.Next(&record), nil)
+		assert(t, record.Get("id"), int32(n))
+	}
+	assert(t, n, 30)
+	assert(t, reader.File(), filepath.Join(dir, "part-3.avro"))
+	if reader.Err() == nil {
+		t.Fatal("expected an error for a file which can't be resolved to the first schema")
+	}
+	assert(t, reader.Close(), nil)
+
+	// a corrupt record stops reading
+	name := filepath.Join(dir, "part-1.avro")
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make the length of the name of the 6th record negative
+	i := -1
+	for n := 0; n < 6; n++ {
+		i += bytes.Index(data[i+1:], []byte("\x0aevent")) + 1
+	}
+	data[i] = 0x7f
+	assert(t, ioutil.WriteFile(name, data, 0644), nil)
+	reader, err = NewDatasetReader(filepath.Join(dir, "*.avro"), DatasetOptions{ReaderSchema: readerSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for ; reader.HasNext(); n++ {
+		var e datasetEvent
+		if err := reader.Next(&e); err != nil {
+			assert(t, reader.Err(), err)
+			break
+		}
+	}
+	assert(t, n, 15)
+	assert(t, reader.HasNext(), false)
+	assert(t, reader.Close(), nil)
+
+	if _, err = NewDatasetReader(filepath.Join(dir, "*.missing"), DatasetOptions{}); err == nil {
+		t.Fatal("expected an error when no files match")
+	}
+}
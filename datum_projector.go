@@ -26,6 +26,7 @@ func (reader *DatumProjector) Read(target interface{}, dec Decoder) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("not applicable for non-pointer types or nil")
 	}
+	startDatum(dec)
 	return reader.projector.Project(rv, dec)
 }
 
@@ -533,6 +534,8 @@ func (p *mapProjector) Project(target reflect.Value, dec Decoder) error {
 
 func newRecordProjector(readerRecordSchema, writerRecordSchema *RecordSchema) (projector, error) {
 	p := &RecordProjector{
+		readerRecordSchema:  readerRecordSchema,
+		writerRecordSchema:  writerRecordSchema,
 		defaultUnwrapperMap: make(map[string]interface{}, 0),
 		defaultIndexMap:     make(map[string]reflect.Value, 0),
 		projectNameMap:      make([]string, len(writerRecordSchema.Fields)),
@@ -571,12 +574,11 @@ NextReaderField:
 				}
 			}
 		}
-		//removed fields
-		if fieldProjector, err := newProjector(writerField.Type, writerField.Type); err != nil {
-			return nil, err
-		} else {
-			p.projectIndexMap[w] = fieldProjector
-		}
+		//removed fields are skipped without decoding them
+		removedType := writerField.Type
+		p.projectIndexMap[w] = &defaultProjector{func(dec Decoder) (interface{}, error) {
+			return nil, SkipDatum(removedType, dec)
+		}}
 	}
 
 	//prepare default values
@@ -606,6 +608,9 @@ type RecordProjector struct {
 }
 
 func (p *RecordProjector) Unwrap(dec Decoder) (interface{}, error) {
+	if err := enterRecord(dec); err != nil {
+		return nil, err
+	}
 	record := NewGenericRecord(p.readerRecordSchema)
 	for f := range p.projectIndexMap {
 		if writerValue, err := p.projectIndexMap[f].Unwrap(dec); err != nil {
@@ -621,12 +626,26 @@ func (p *RecordProjector) Unwrap(dec Decoder) (interface{}, error) {
 			record.Set(d, p.defaultUnwrapperMap[d])
 		}
 	}
+	leaveRecord(dec)
 	return record, nil
 }
 
+var genericRecordPtrType = reflect.TypeOf((*GenericRecord)(nil))
+
 func (p *RecordProjector) Project(target reflect.Value, dec Decoder) error {
-	if target.Kind() == reflect.Ptr && !target.Elem().IsValid() {
-		target.Set(reflect.New(target.Type().Elem()))
+	if err := enterRecord(dec); err != nil {
+		return err
+	}
+	if target.Kind() == reflect.Ptr && target.Elem().Kind() == reflect.Ptr {
+		//e.g. **GenericRecord, the library allocates the record
+		target = target.Elem()
+	}
+	if target.Kind() == reflect.Ptr && target.IsNil() {
+		if target.Type() == genericRecordPtrType {
+			target.Set(reflect.ValueOf(NewGenericRecord(p.readerRecordSchema)))
+		} else {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
 	}
 	target = dereference(target)
 	switch target.Interface().(type) {
@@ -653,7 +672,10 @@ func (p *RecordProjector) Project(target reflect.Value, dec Decoder) error {
 			if !structField.IsValid() {
 				structField = target.FieldByName(strings.Title(p.projectNameMap[f]))
 				if !structField.IsValid() {
-					p.projectIndexMap[f].Unwrap(dec) //still have to read deleted fields from the writer value
+					//still have to read deleted fields from the writer value
+					if _, err := p.projectIndexMap[f].Unwrap(dec); err != nil {
+						return err
+					}
 					continue
 				}
 			}
@@ -674,5 +696,6 @@ func (p *RecordProjector) Project(target reflect.Value, dec Decoder) error {
 			}
 		}
 	}
+	leaveRecord(dec)
 	return nil
 }
@@ -179,6 +179,7 @@ func (reader *SpecificDatumReader) SetSchema(schema Schema) DatumReader {
 // your struct field as follows: SomeValue int32 `avro:"some_field"`).
 // May return an error indicating a read failure.
 func (reader *SpecificDatumReader) Read(v interface{}, dec Decoder) error {
+	startDatum(dec)
 	if reader, ok := v.(Unmarshaler); ok {
 		return reader.UnmarshalAvro(dec)
 	}
@@ -350,7 +351,7 @@ func (reader sDatumReader) mapMap(field Schema, reflectField reflect.Value, dec
 			}
 			val, err := reader.readValue(field.(*MapSchema).Values, dest, dec)
 			if err != nil {
-				return reflect.ValueOf(mapLength), nil
+				return reflect.ValueOf(mapLength), err
 			}
 			if !elemIsPointer && val.Kind() == reflect.Ptr {
 				resultMap.SetMapIndex(key, val.Elem())
@@ -428,6 +429,9 @@ func (reader sDatumReader) mapRecord(field Schema, reflectField reflect.Value, d
 }
 
 func (reader sDatumReader) fillRecord(field Schema, record reflect.Value, dec Decoder) error {
+	if err := enterRecord(dec); err != nil {
+		return err
+	}
 	if pf, ok := field.(*preparedRecordSchema); ok {
 		plan, err := pf.getPlan(record.Type().Elem())
 		if err != nil {
@@ -456,6 +460,7 @@ func (reader sDatumReader) fillRecord(field Schema, record reflect.Value, dec De
 			}
 		}
 	}
+	leaveRecord(dec)
 	return nil
 }
 
@@ -483,6 +488,7 @@ func (reader *GenericDatumReader) SetSchema(schema Schema) DatumReader {
 // Accepts a value to fill with data and a Decoder to read from. Given value MUST be of pointer type.
 // May return an error indicating a read failure.
 func (reader *GenericDatumReader) Read(v interface{}, dec Decoder) error {
+	startDatum(dec)
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("Not applicable for non-pointer types or nil")
@@ -676,6 +682,9 @@ func (reader *GenericDatumReader) mapFixed(field Schema, dec Decoder) ([]byte, e
 }
 
 func (reader *GenericDatumReader) mapRecord(field Schema, dec Decoder) (*GenericRecord, error) {
+	if err := enterRecord(dec); err != nil {
+		return nil, err
+	}
 	record := NewGenericRecord(field)
 
 	recordSchema := assertRecordSchema(field)
@@ -686,5 +695,6 @@ func (reader *GenericDatumReader) mapRecord(field Schema, dec Decoder) (*Generic
 		}
 	}
 
+	leaveRecord(dec)
 	return record, nil
 }
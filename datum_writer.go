@@ -63,6 +63,15 @@ func (w *anyDatumWriter) Write(obj interface{}, enc Encoder) error {
 	}
 }
 
+// encoderErr returns the write error recorded by an Encoder which reports
+// them, such as a BufferedEncoder or JSONEncoder.
+func encoderErr(enc Encoder) error {
+	if e, ok := enc.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
 // coerce interfaces
 var _ DatumWriter = (*GenericDatumWriter)(nil)
 var _ DatumWriter = (*SpecificDatumWriter)(nil)
@@ -92,7 +101,10 @@ func (writer *SpecificDatumWriter) SetSchema(schema Schema) DatumWriter {
 // May return an error indicating a write failure.
 func (writer *SpecificDatumWriter) Write(obj interface{}, enc Encoder) error {
 	if writer, ok := obj.(Marshaler); ok {
-		return writer.MarshalAvro(enc)
+		if err := writer.MarshalAvro(enc); err != nil {
+			return err
+		}
+		return encoderErr(enc)
 	}
 
 	rv := reflect.ValueOf(obj)
@@ -101,7 +113,10 @@ func (writer *SpecificDatumWriter) Write(obj interface{}, enc Encoder) error {
 		return ErrSchemaNotSet
 	}
 
-	return writer.write(rv, enc, writer.schema)
+	if err := writer.write(rv, enc, writer.schema); err != nil {
+		return err
+	}
+	return encoderErr(enc)
 }
 
 func (writer *SpecificDatumWriter) write(v reflect.Value, enc Encoder, s Schema) error {
@@ -145,7 +160,7 @@ func (writer *SpecificDatumWriter) writeBoolean(v reflect.Value, enc Encoder, s
 		return fmt.Errorf("Invalid boolean value: %v", v.Interface())
 	}
 
-	enc.WriteBoolean(dereference(v).Interface().(bool))
+	enc.WriteBoolean(elementOf(v).Bool())
 	return nil
 }
 
@@ -154,7 +169,7 @@ func (writer *SpecificDatumWriter) writeInt(v reflect.Value, enc Encoder, s Sche
 		return fmt.Errorf("Invalid int value: %v", v.Interface())
 	}
 
-	enc.WriteInt(dereference(v).Interface().(int32))
+	enc.WriteInt(int32(elementOf(v).Int()))
 	return nil
 }
 
@@ -163,7 +178,7 @@ func (writer *SpecificDatumWriter) writeLong(v reflect.Value, enc Encoder, s Sch
 		return fmt.Errorf("Invalid long value: %v", v.Interface())
 	}
 
-	enc.WriteLong(dereference(v).Interface().(int64))
+	enc.WriteLong(elementOf(v).Int())
 	return nil
 }
 
@@ -172,7 +187,7 @@ func (writer *SpecificDatumWriter) writeFloat(v reflect.Value, enc Encoder, s Sc
 		return fmt.Errorf("Invalid float value: %v", v.Interface())
 	}
 
-	enc.WriteFloat(dereference(v).Interface().(float32))
+	enc.WriteFloat(float32(elementOf(v).Float()))
 	return nil
 }
 
@@ -181,7 +196,7 @@ func (writer *SpecificDatumWriter) writeDouble(v reflect.Value, enc Encoder, s S
 		return fmt.Errorf("Invalid double value: %v", v.Interface())
 	}
 
-	enc.WriteDouble(dereference(v).Interface().(float64))
+	enc.WriteDouble(elementOf(v).Float())
 	return nil
 }
 
@@ -190,7 +205,7 @@ func (writer *SpecificDatumWriter) writeBytes(v reflect.Value, enc Encoder, s Sc
 		return fmt.Errorf("Invalid bytes value: %v", v.Interface())
 	}
 
-	enc.WriteBytes(dereference(v).Interface().([]byte))
+	enc.WriteBytes(elementOf(v).Bytes())
 	return nil
 }
 
@@ -199,7 +214,7 @@ func (writer *SpecificDatumWriter) writeString(v reflect.Value, enc Encoder, s S
 		return fmt.Errorf("Invalid string value: %v", v.Interface())
 	}
 
-	enc.WriteString(dereference(v).Interface().(string))
+	enc.WriteString(elementOf(v).String())
 	return nil
 }
 
@@ -339,7 +354,10 @@ func (writer *GenericDatumWriter) SetSchema(schema Schema) DatumWriter {
 // Accepts a value to write and Encoder to write to.
 // May return an error indicating a write failure.
 func (writer *GenericDatumWriter) Write(obj interface{}, enc Encoder) error {
-	return writer.write(obj, enc, writer.schema)
+	if err := writer.write(obj, enc, writer.schema); err != nil {
+		return err
+	}
+	return encoderErr(enc)
 }
 
 func (writer *GenericDatumWriter) write(v interface{}, enc Encoder, s Schema) error {
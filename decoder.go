@@ -65,15 +65,17 @@ const maxLongBufSize = 10
 type binaryDecoder struct {
 	buf []byte
 	pos int64
+	decoderLimiter
 }
 
 type binaryDecoderReader struct {
 	r io.Reader
+	decoderLimiter
 }
 
 // NewBinaryDecoder creates a new BinaryDecoder to read from a given buffer.
 func NewBinaryDecoder(buf []byte) Decoder {
-	return &binaryDecoder{buf, 0}
+	return &binaryDecoder{buf: buf}
 }
 
 // NewBinaryDecoderReader creates a new BinaryDecoder to read from a given io.Reader.
@@ -206,6 +208,9 @@ func (bd *binaryDecoder) ReadString() (string, error) {
 	if err != nil || length < 0 {
 		return "", ErrInvalidStringLength
 	}
+	if err := bd.checkLength(length); err != nil {
+		return "", err
+	}
 	if err := checkEOF(bd.buf, bd.pos, int(length)); err != nil {
 		return "", err
 	}
@@ -221,6 +226,9 @@ func (bdr *binaryDecoderReader) ReadString() (string, error) {
 	} else if l64 < 0 {
 		return "", ErrInvalidStringLength
 	}
+	if err := bdr.checkLength(l64); err != nil {
+		return "", err
+	}
 	length := int(l64)
 	/*
 		if buf, err := bdr.r.Peek(length); err == nil {
@@ -277,6 +285,9 @@ func (bd *binaryDecoder) ReadBytes() ([]byte, error) {
 	if length < 0 {
 		return nil, ErrNegativeBytesLength
 	}
+	if err = bd.checkLength(length); err != nil {
+		return nil, err
+	}
 	if err = checkEOF(bd.buf, bd.pos, int(length)); err != nil {
 		return nil, ErrUnexpectedEOF
 	}
@@ -294,6 +305,8 @@ func (bdr *binaryDecoderReader) ReadBytes() ([]byte, error) {
 		return nil, err
 	} else if length < 0 {
 		return nil, ErrNegativeBytesLength
+	} else if err = bdr.checkLength(length); err != nil {
+		return nil, err
 	}
 
 	buf := make([]byte, length)
@@ -349,17 +362,29 @@ func (bdr *binaryDecoderReader) ReadDouble() (f float64, err error) {
 
 func (bd *binaryDecoder) ReadNull() (interface{}, error) { return nil, nil }
 func (bd *binaryDecoder) ReadEnum() (int32, error)       { return bd.ReadInt() }
-func (bd *binaryDecoder) ReadArrayStart() (int64, error) { return bd.readItemCount() }
-func (bd *binaryDecoder) ArrayNext() (int64, error)      { return bd.readItemCount() }
-func (bd *binaryDecoder) ReadMapStart() (int64, error)   { return bd.readItemCount() }
-func (bd *binaryDecoder) MapNext() (int64, error)        { return bd.readItemCount() }
+func (bd *binaryDecoder) ReadArrayStart() (int64, error) { return bd.startItems(bd.readItemCount()) }
+func (bd *binaryDecoder) ArrayNext() (int64, error)      { return bd.nextItems(bd.readItemCount()) }
+func (bd *binaryDecoder) ReadMapStart() (int64, error)   { return bd.startItems(bd.readItemCount()) }
+func (bd *binaryDecoder) MapNext() (int64, error)        { return bd.nextItems(bd.readItemCount()) }
 
 func (bdr *binaryDecoderReader) ReadNull() (interface{}, error) { return nil, nil }
 func (bdr *binaryDecoderReader) ReadEnum() (int32, error)       { return bdr.ReadInt() }
-func (bdr *binaryDecoderReader) ReadArrayStart() (int64, error) { return bdr.readItemCount() }
-func (bdr *binaryDecoderReader) ArrayNext() (int64, error)      { return bdr.readItemCount() }
-func (bdr *binaryDecoderReader) ReadMapStart() (int64, error)   { return bdr.readItemCount() }
-func (bdr *binaryDecoderReader) MapNext() (int64, error)        { return bdr.readItemCount() }
+
+func (bdr *binaryDecoderReader) ReadArrayStart() (int64, error) {
+	return bdr.startItems(bdr.readItemCount())
+}
+
+func (bdr *binaryDecoderReader) ArrayNext() (int64, error) {
+	return bdr.nextItems(bdr.readItemCount())
+}
+
+func (bdr *binaryDecoderReader) ReadMapStart() (int64, error) {
+	return bdr.startItems(bdr.readItemCount())
+}
+
+func (bdr *binaryDecoderReader) MapNext() (int64, error) {
+	return bdr.nextItems(bdr.readItemCount())
+}
 
 func (bd *binaryDecoder) ReadFixed(bytes []byte) error {
 	const start = 0
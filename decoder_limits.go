@@ -0,0 +1,213 @@
+package avro
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// DecoderLimits bounds the resources decoding a single datum may use, to
+// safely read data from untrusted sources. The lengths of bytes and strings
+// and the counts of array and map blocks are read off the wire, so without
+// limits a few bytes of malicious input can make a decoder allocate
+// gigabytes or loop for a very long time. Any limit which is zero is not
+// enforced, the zero value doesn't limit anything. Exceeding a limit is
+// reported as a *LimitError.
+type DecoderLimits struct {
+	// MaxBytesLength is the maximum length of a bytes or string value.
+	MaxBytesLength int64
+
+	// MaxItems is the maximum number of items of an array or entries of a
+	// map, over all of its blocks.
+	MaxItems int64
+
+	// MaxAllocation is the maximum number of bytes allocated while reading
+	// a datum, counting the lengths of bytes and strings and 8 bytes for
+	// every item of an array or map.
+	MaxAllocation int64
+
+	// MaxDepth is the maximum nesting of records, arrays and maps, which
+	// bounds the recursion of reading recursive schemas. Records are only
+	// counted when read with one of the datum readers or SkipDatum.
+	MaxDepth int
+
+	// MaxBlockSize is the maximum size of a block of an object container
+	// file, both as stored and decompressed. Only used by DataFileReader,
+	// which stops decompressing a block once it exceeds the limit if the
+	// codec is a LimitedCodec, so the Value of the LimitError may be less
+	// than the actual decompressed size.
+	MaxBlockSize int64
+}
+
+// LimitError is returned when decoding exceeds one of the DecoderLimits.
+type LimitError struct {
+	// Limit is the name of the field of DecoderLimits which was exceeded.
+	Limit string
+
+	// Max is the value of the limit and Value the one which exceeded it.
+	Max, Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Decoder limit exceeded: %s is %d, got %d", e.Limit, e.Max, e.Value)
+}
+
+// NewBinaryDecoderWithLimits creates a BinaryDecoder reading from a given
+// buffer which enforces the given limits.
+func NewBinaryDecoderWithLimits(buf []byte, limits DecoderLimits) Decoder {
+	return &binaryDecoder{buf: buf, decoderLimiter: newDecoderLimiter(limits)}
+}
+
+// NewBinaryDecoderReaderWithLimits creates a BinaryDecoder reading from a
+// given io.Reader which enforces the given limits, see NewBinaryDecoderReader.
+func NewBinaryDecoderReaderWithLimits(r io.Reader, limits DecoderLimits) Decoder {
+	return &binaryDecoderReader{r: r, decoderLimiter: newDecoderLimiter(limits)}
+}
+
+// Bytes accounted for every item of an array or map by MaxAllocation.
+const itemAllocation = 8
+
+// limitedDecoder is implemented by decoders which enforce DecoderLimits.
+// The datum readers tell it where datums and records start and end, which
+// the binary encoding doesn't.
+type limitedDecoder interface {
+	startDatum()
+	enterRecord() error
+	leaveRecord()
+}
+
+// startDatum resets the allocations and depth counted by dec, if it enforces limits.
+func startDatum(dec Decoder) {
+	if l, ok := dec.(limitedDecoder); ok {
+		l.startDatum()
+	}
+}
+
+// enterRecord counts the depth of a record about to be read from dec, if it enforces limits.
+func enterRecord(dec Decoder) error {
+	if l, ok := dec.(limitedDecoder); ok {
+		return l.enterRecord()
+	}
+	return nil
+}
+
+// leaveRecord ends a record started with enterRecord.
+func leaveRecord(dec Decoder) {
+	if l, ok := dec.(limitedDecoder); ok {
+		l.leaveRecord()
+	}
+}
+
+// decoderLimiter keeps track of what a datum has used so far, it's embedded
+// by the binary decoders.
+type decoderLimiter struct {
+	limits    DecoderLimits
+	limited   bool
+	allocated int64
+	records   int
+	items     []int64 // items read so far of every array or map being read
+}
+
+func newDecoderLimiter(limits DecoderLimits) decoderLimiter {
+	return decoderLimiter{limits: limits, limited: limits != DecoderLimits{}}
+}
+
+func (l *decoderLimiter) startDatum() {
+	l.allocated, l.records, l.items = 0, 0, l.items[:0]
+}
+
+func (l *decoderLimiter) enterRecord() error {
+	if !l.limited {
+		return nil
+	}
+	l.records++
+	return l.checkDepth()
+}
+
+func (l *decoderLimiter) leaveRecord() {
+	if l.records > 0 {
+		l.records--
+	}
+}
+
+func (l *decoderLimiter) checkDepth() error {
+	depth := l.records + len(l.items)
+	if max := l.limits.MaxDepth; max > 0 && depth > max {
+		return &LimitError{"MaxDepth", int64(max), int64(depth)}
+	}
+	return nil
+}
+
+// checkLength checks the length of a bytes or string value before it's allocated.
+func (l *decoderLimiter) checkLength(length int64) error {
+	if !l.limited {
+		return nil
+	}
+	if max := l.limits.MaxBytesLength; max > 0 && length > max {
+		return &LimitError{"MaxBytesLength", max, length}
+	}
+	return l.allocate(length)
+}
+
+func (l *decoderLimiter) allocate(n int64) error {
+	l.allocated = saturatingAdd(l.allocated, n)
+	if max := l.limits.MaxAllocation; max > 0 && l.allocated > max {
+		return &LimitError{"MaxAllocation", max, l.allocated}
+	}
+	return nil
+}
+
+// startItems checks the count of the first block of an array or map.
+func (l *decoderLimiter) startItems(count int64, err error) (int64, error) {
+	if err != nil || !l.limited || count == 0 {
+		return count, err
+	}
+	l.items = append(l.items, 0)
+	if err := l.checkDepth(); err != nil {
+		return 0, err
+	}
+	return l.nextItems(count, nil)
+}
+
+// nextItems checks the count of a following block of an array or map,
+// which ends it if 0.
+func (l *decoderLimiter) nextItems(count int64, err error) (int64, error) {
+	if err != nil || !l.limited || len(l.items) == 0 {
+		return count, err
+	}
+	top := len(l.items) - 1
+	if count == 0 {
+		l.items = l.items[:top]
+		return 0, nil
+	}
+	l.items[top] = saturatingAdd(l.items[top], count)
+	if max := l.limits.MaxItems; max > 0 && l.items[top] > max {
+		return 0, &LimitError{"MaxItems", max, l.items[top]}
+	}
+	allocation := int64(math.MaxInt64)
+	if count <= math.MaxInt64/itemAllocation {
+		allocation = count * itemAllocation
+	}
+	if err := l.allocate(allocation); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// checkSkippedItems checks the count of a block of an array or map being
+// skipped, which is only bounded per block as nothing is allocated.
+func (l *decoderLimiter) checkSkippedItems(count int64) error {
+	if max := l.limits.MaxItems; l.limited && max > 0 && count > max {
+		return &LimitError{"MaxItems", max, count}
+	}
+	return nil
+}
+
+// saturatingAdd adds two non-negative values, the counts read off the wire
+// may be large enough to overflow.
+func saturatingAdd(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
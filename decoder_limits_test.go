@@ -0,0 +1,257 @@
+package avro
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func assertLimitError(t *testing.T, err error, limit string) {
+	t.Helper()
+	limitErr, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("Expected a *LimitError of %s, actual %v", limit, err)
+	}
+	assert(t, limitErr.Limit, limit)
+}
+
+func limitedDecoders(data []byte, limits DecoderLimits) map[string]Decoder {
+	return map[string]Decoder{
+		"buffer": NewBinaryDecoderWithLimits(data, limits),
+		"reader": NewBinaryDecoderReaderWithLimits(bytes.NewReader(data), limits),
+	}
+}
+
+func TestDecoderLimits_bytesLength(t *testing.T) {
+	// a 6 byte message announcing a terabyte of data
+	data := appendVarint64(nil, 1<<40)
+	limits := DecoderLimits{MaxBytesLength: 1024}
+	for _, dec := range limitedDecoders(data, limits) {
+		_, err := dec.ReadString()
+		assertLimitError(t, err, "MaxBytesLength")
+	}
+	for _, dec := range limitedDecoders(data, limits) {
+		_, err := dec.ReadBytes()
+		assertLimitError(t, err, "MaxBytesLength")
+	}
+}
+
+func TestDecoderLimits_items(t *testing.T) {
+	schema := MustParseSchema(`{"type": "array", "items": "null"}`)
+	reader := NewGenericDatumReader().SetSchema(schema)
+
+	// items of null take no input at all, a single block would never end
+	endless := appendVarint64(nil, 1<<62)
+	for _, dec := range limitedDecoders(endless, DecoderLimits{MaxItems: 1000}) {
+		var v interface{}
+		assertLimitError(t, reader.Read(&v, dec), "MaxItems")
+	}
+
+	// the limit applies to all blocks of an array together
+	var blocks []byte
+	for i := 0; i < 3; i++ {
+		blocks = appendVarint64(blocks, 600)
+	}
+	for _, dec := range limitedDecoders(blocks, DecoderLimits{MaxItems: 1000}) {
+		var v interface{}
+		assertLimitError(t, reader.Read(&v, dec), "MaxItems")
+	}
+	for _, dec := range limitedDecoders(append(blocks, 0), DecoderLimits{MaxItems: 2000}) {
+		var v interface{}
+		assert(t, reader.Read(&v, dec), nil)
+		assert(t, len(v.([]interface{})), 1800)
+	}
+}
+
+func TestDecoderLimits_allocation(t *testing.T) {
+	schema := MustParseSchema(`{"type": "map", "values": "string"}`)
+	reader := NewGenericDatumReader().SetSchema(schema)
+	value := strings.Repeat("x", 40)
+	datum := func(entries int) []byte {
+		buf := &bytes.Buffer{}
+		enc := NewBinaryEncoder(buf)
+		enc.WriteMapStart(int64(entries))
+		for i := 0; i < entries; i++ {
+			enc.WriteString(string(rune('a' + i)))
+			enc.WriteString(value)
+		}
+		enc.WriteMapNext(0)
+		return buf.Bytes()
+	}
+
+	for _, dec := range limitedDecoders(datum(3), DecoderLimits{MaxAllocation: 100}) {
+		var v interface{}
+		assertLimitError(t, reader.Read(&v, dec), "MaxAllocation")
+	}
+
+	// the allocations are counted per datum
+	data := append(datum(1), datum(1)...)
+	for _, dec := range limitedDecoders(data, DecoderLimits{MaxAllocation: 100}) {
+		for i := 0; i < 2; i++ {
+			var v interface{}
+			assert(t, reader.Read(&v, dec), nil)
+			assert(t, v.(map[string]interface{})["a"], value)
+		}
+	}
+}
+
+const limitsTestListSchemaRaw = `{"type": "record", "name": "Node", "fields": [
+	{"name": "next", "type": ["null", "Node"]}
+]}`
+
+type limitsTestNode struct {
+	Next *limitsTestNode `avro:"next"`
+}
+
+// limitsTestList encodes a list of the given number of Nodes.
+func limitsTestList(length int) []byte {
+	var data []byte
+	for i := 1; i < length; i++ {
+		data = appendVarint64(data, 1)
+	}
+	return appendVarint64(data, 0)
+}
+
+func TestDecoderLimits_depth(t *testing.T) {
+	schema := MustParseSchema(limitsTestListSchemaRaw)
+	data := limitsTestList(10)
+	readers := map[string]func(Decoder) error{
+		"generic": func(dec Decoder) error {
+			var v interface{}
+			return NewGenericDatumReader().SetSchema(schema).Read(&v, dec)
+		},
+		"specific": func(dec Decoder) error {
+			return NewSpecificDatumReader().SetSchema(schema).Read(&limitsTestNode{}, dec)
+		},
+		"prepared": func(dec Decoder) error {
+			return NewSpecificDatumReader().SetSchema(Prepare(schema)).Read(&limitsTestNode{}, dec)
+		},
+		"skip": func(dec Decoder) error {
+			return SkipDatum(schema, dec)
+		},
+	}
+	for name, read := range readers {
+		for _, dec := range limitedDecoders(data, DecoderLimits{MaxDepth: 5}) {
+			assertLimitError(t, read(dec), "MaxDepth")
+		}
+		for _, dec := range limitedDecoders(data, DecoderLimits{MaxDepth: 10}) {
+			if err := read(dec); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+	}
+
+	nested := MustParseSchema(`{"type": "record", "name": "A", "fields": [
+		{"name": "b", "type": {"type": "record", "name": "B", "fields": [
+			{"name": "c", "type": {"type": "record", "name": "C", "fields": [{"name": "x", "type": "int"}]}}
+		]}}
+	]}`)
+	projector, err := NewDatumProjector(nested, nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *GenericRecord
+	assertLimitError(t, projector.Read(&v, NewBinaryDecoderWithLimits([]byte{0}, DecoderLimits{MaxDepth: 2})), "MaxDepth")
+	assert(t, projector.Read(&v, NewBinaryDecoderWithLimits([]byte{0}, DecoderLimits{MaxDepth: 3})), nil)
+}
+
+func TestDataFileReader_limits(t *testing.T) {
+	schema := MustParseSchema(`{"type": "record", "name": "R", "fields": [{"name": "s", "type": "string"}]}`)
+	buf := &bytes.Buffer{}
+	dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: "deflate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := NewGenericRecord(schema)
+	record.Set("s", strings.Repeat("x", 1000))
+	for i := 0; i < 10; i++ {
+		assert(t, dfw.Write(record), nil)
+	}
+	assert(t, dfw.Close(), nil)
+
+	_, err = NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+		Limits: DecoderLimits{MaxBlockSize: 10},
+	})
+	assertLimitError(t, err, "MaxBlockSize")
+
+	// the block is far smaller compressed than decompressed, it's only
+	// decompressed once records are read from it
+	dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+		Limits: DecoderLimits{MaxBlockSize: 5000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLimitError(t, dfr.Next(NewGenericRecord(schema)), "MaxBlockSize")
+
+	// the schema in the header is longer than this
+	_, err = NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+		Limits: DecoderLimits{MaxBytesLength: 100},
+	})
+	assertLimitError(t, err, "MaxBytesLength")
+
+	dfr, err = NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+		Limits: DecoderLimits{MaxBytesLength: 500},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLimitError(t, dfr.Next(NewGenericRecord(schema)), "MaxBytesLength")
+}
+
+func TestDataFileReader_decompressionBomb(t *testing.T) {
+	schema := MustParseSchema(`{"type": "record", "name": "R", "fields": [{"name": "b", "type": "bytes"}]}`)
+	record := NewGenericRecord(schema)
+	record.Set("b", make([]byte, 64<<20))
+	// xz isn't tested, its decoder allocates the whole dictionary the stream
+	// declares, 8MB for the writer's default
+	for _, codec := range []string{"deflate", "snappy", "zstandard", "bzip2"} {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, dfw.Write(record), nil)
+		assert(t, dfw.Close(), nil)
+		// snappy can't compress this below 3MB
+		limit := int64(4 << 20)
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+			Limits: DecoderLimits{MaxBlockSize: limit},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dfr.Next(NewGenericRecord(schema))
+		runtime.ReadMemStats(&after)
+		assertLimitError(t, err, "MaxBlockSize")
+		// decompressing the whole block would allocate at least 64MB
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(8*limit) {
+			t.Fatalf("%s: %d bytes allocated reading a block of %d bytes", codec, allocated, buf.Len())
+		}
+	}
+	// blocks within the limit are decompressed as usual
+	record.Set("b", []byte("small"))
+	for _, codec := range []string{"null", "deflate", "snappy", "zstandard", "bzip2", "xz"} {
+		buf := &bytes.Buffer{}
+		dfw, err := NewDataFileWriterWithOptions(buf, schema, NewDatumWriter(schema), DataFileWriterOptions{Codec: codec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, dfw.Write(record), nil)
+		assert(t, dfw.Close(), nil)
+		dfr, err := NewDataFileStreamReader(bytes.NewReader(buf.Bytes()), DataFileReaderOptions{
+			Limits: DecoderLimits{MaxBlockSize: 64},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := NewGenericRecord(schema)
+		assert(t, dfr.Next(actual), nil)
+		assert(t, actual.Get("b"), []byte("small"))
+	}
+}
@@ -0,0 +1,268 @@
+package avro
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// SkipDecoder is a Decoder which can skip over values without decoding
+// them, which both binary decoders implement. SkipDatum uses it when
+// available.
+type SkipDecoder interface {
+	Decoder
+
+	// SkipBytes skips a bytes value.
+	SkipBytes() error
+
+	// SkipString skips a string value.
+	SkipString() error
+
+	// SkipFixed skips a fixed value of the given size.
+	SkipFixed(size int) error
+
+	// SkipArray jumps over the blocks of an array whose size in bytes is
+	// known and returns the number of items in the next block, which the
+	// caller has to skip one by one before calling SkipArray again. Returns
+	// 0 at the end of the array.
+	SkipArray() (int64, error)
+
+	// SkipMap is like SkipArray for the entries of a map.
+	SkipMap() (int64, error)
+}
+
+var _ SkipDecoder = (*binaryDecoder)(nil)
+var _ SkipDecoder = (*binaryDecoderReader)(nil)
+
+// SkipDatum reads past a datum of the given schema without materialising
+// it. Arrays and maps written in blocks with a known size in bytes are
+// jumped over if dec is a SkipDecoder, any other Decoder reads and discards
+// the values.
+func SkipDatum(schema Schema, dec Decoder) error {
+	skipper, _ := dec.(SkipDecoder)
+	return skipDatum(schema, dec, skipper)
+}
+
+func skipDatum(schema Schema, dec Decoder, skipper SkipDecoder) error {
+	var err error
+	switch schema.Type() {
+	case Null:
+	case Boolean:
+		_, err = dec.ReadBoolean()
+	case Int:
+		_, err = dec.ReadInt()
+	case Long:
+		_, err = dec.ReadLong()
+	case Float:
+		_, err = dec.ReadFloat()
+	case Double:
+		_, err = dec.ReadDouble()
+	case Enum:
+		_, err = dec.ReadEnum()
+	case Bytes:
+		if skipper != nil {
+			return skipper.SkipBytes()
+		}
+		_, err = dec.ReadBytes()
+	case String:
+		if skipper != nil {
+			return skipper.SkipString()
+		}
+		_, err = dec.ReadString()
+	case Fixed:
+		size := schema.(*FixedSchema).Size
+		if skipper != nil {
+			return skipper.SkipFixed(size)
+		}
+		err = dec.ReadFixed(make([]byte, size))
+	case Array:
+		items := schema.(*ArraySchema).Items
+		start, next := dec.ReadArrayStart, dec.ArrayNext
+		if skipper != nil {
+			start, next = skipper.SkipArray, skipper.SkipArray
+		}
+		return skipItems(start, next, func() error {
+			return skipDatum(items, dec, skipper)
+		})
+	case Map:
+		values := schema.(*MapSchema).Values
+		start, next := dec.ReadMapStart, dec.MapNext
+		if skipper != nil {
+			start, next = skipper.SkipMap, skipper.SkipMap
+		}
+		return skipItems(start, next, func() error {
+			if err := skipDatum(&StringSchema{}, dec, skipper); err != nil {
+				return err
+			}
+			return skipDatum(values, dec, skipper)
+		})
+	case Union:
+		types := schema.(*UnionSchema).Types
+		index, err := dec.ReadInt()
+		if err != nil {
+			return err
+		}
+		if index < 0 || int(index) >= len(types) {
+			return ErrUnionTypeOverflow
+		}
+		return skipDatum(types[index], dec, skipper)
+	case Record:
+		if err := enterRecord(dec); err != nil {
+			return err
+		}
+		for _, field := range assertRecordSchema(schema).Fields {
+			if err := skipDatum(field.Type, dec, skipper); err != nil {
+				return err
+			}
+		}
+		leaveRecord(dec)
+	case Recursive:
+		return skipDatum(schema.(*RecursiveSchema).Actual, dec, skipper)
+	}
+	return err
+}
+
+// skipItems skips the items of an array or map block by block.
+func skipItems(start, next func() (int64, error), skipItem func() error) error {
+	count, err := start()
+	for ; err == nil && count > 0; count, err = next() {
+		for i := int64(0); i < count; i++ {
+			if err := skipItem(); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// SkipBytes skips a bytes value.
+func (bd *binaryDecoder) SkipBytes() error {
+	length, err := bd.ReadLong()
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return ErrNegativeBytesLength
+	}
+	return bd.skip(length)
+}
+
+// SkipString skips a string value.
+func (bd *binaryDecoder) SkipString() error {
+	length, err := bd.ReadLong()
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return ErrInvalidStringLength
+	}
+	return bd.skip(length)
+}
+
+// SkipFixed skips a fixed value of the given size.
+func (bd *binaryDecoder) SkipFixed(size int) error {
+	return bd.skip(int64(size))
+}
+
+// SkipArray jumps over the array blocks whose size is known and returns the number of items in the next block.
+func (bd *binaryDecoder) SkipArray() (int64, error) { return bd.skipBlocks() }
+
+// SkipMap jumps over the map blocks whose size is known and returns the number of entries in the next block.
+func (bd *binaryDecoder) SkipMap() (int64, error) { return bd.skipBlocks() }
+
+func (bd *binaryDecoder) skipBlocks() (int64, error) {
+	for {
+		count, err := bd.ReadLong()
+		if err != nil {
+			return 0, err
+		}
+		if count >= 0 {
+			return count, bd.checkSkippedItems(count)
+		}
+		size, err := bd.ReadLong()
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 {
+			return 0, ErrInvalidBlockSize
+		}
+		if err := bd.skip(size); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (bd *binaryDecoder) skip(n int64) error {
+	if int64(len(bd.buf))-bd.pos < n {
+		return ErrUnexpectedEOF
+	}
+	bd.pos += n
+	return nil
+}
+
+// SkipBytes skips a bytes value.
+func (bdr *binaryDecoderReader) SkipBytes() error {
+	length, err := bdr.ReadLong()
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return ErrNegativeBytesLength
+	}
+	return bdr.skip(length)
+}
+
+// SkipString skips a string value.
+func (bdr *binaryDecoderReader) SkipString() error {
+	length, err := bdr.ReadLong()
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return ErrInvalidStringLength
+	}
+	return bdr.skip(length)
+}
+
+// SkipFixed skips a fixed value of the given size.
+func (bdr *binaryDecoderReader) SkipFixed(size int) error {
+	return bdr.skip(int64(size))
+}
+
+// SkipArray jumps over the array blocks whose size is known and returns the number of items in the next block.
+func (bdr *binaryDecoderReader) SkipArray() (int64, error) { return bdr.skipBlocks() }
+
+// SkipMap jumps over the map blocks whose size is known and returns the number of entries in the next block.
+func (bdr *binaryDecoderReader) SkipMap() (int64, error) { return bdr.skipBlocks() }
+
+func (bdr *binaryDecoderReader) skipBlocks() (int64, error) {
+	for {
+		count, err := bdr.ReadLong()
+		if err != nil {
+			return 0, err
+		}
+		if count >= 0 {
+			return count, bdr.checkSkippedItems(count)
+		}
+		size, err := bdr.ReadLong()
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 {
+			return 0, ErrInvalidBlockSize
+		}
+		if err := bdr.skip(size); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// skip discards n bytes of input, without copying them if the reader can
+// discard, e.g. a bufio.Reader.
+func (bdr *binaryDecoderReader) skip(n int64) error {
+	if discarder, ok := bdr.r.(interface{ Discard(int) (int, error) }); ok {
+		_, err := discarder.Discard(int(n))
+		return eofUnexpected(err)
+	}
+	_, err := io.CopyN(ioutil.Discard, bdr.r, n)
+	return eofUnexpected(err)
+}
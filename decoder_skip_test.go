@@ -0,0 +1,110 @@
+package avro
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const skipTestSchemaRaw = `{"type": "record", "name": "Skipped", "fields": [
+	{"name": "a", "type": {"type": "array", "items": "string"}},
+	{"name": "m", "type": {"type": "map", "values": "long"}},
+	{"name": "u", "type": ["null", "bytes"]},
+	{"name": "f", "type": {"type": "fixed", "name": "F", "size": 3}},
+	{"name": "e", "type": {"type": "enum", "name": "E", "symbols": ["X", "Y"]}},
+	{"name": "d", "type": "double"},
+	{"name": "r", "type": {"type": "record", "name": "Inner", "fields": [{"name": "s", "type": "string"}]}}
+]}`
+
+// skipTestData encodes a datum of skipTestSchemaRaw followed by the long 42.
+func skipTestData() []byte {
+	buf := &bytes.Buffer{}
+	enc := NewBinaryEncoder(buf)
+	// a block with a size in bytes, whose content is not even valid as it should be jumped over
+	enc.WriteArrayStart(-2)
+	enc.WriteLong(3)
+	enc.WriteRaw([]byte{1, 1, 1})
+	enc.WriteArrayNext(1)
+	enc.WriteString("item")
+	enc.WriteArrayNext(0)
+	enc.WriteMapStart(1)
+	enc.WriteString("key")
+	enc.WriteLong(5)
+	enc.WriteMapNext(0)
+	enc.WriteLong(1)
+	enc.WriteBytes([]byte("bytes"))
+	enc.WriteRaw([]byte("fix"))
+	enc.WriteInt(1)
+	enc.WriteDouble(1.5)
+	enc.WriteString("inner")
+	enc.WriteLong(42)
+	return buf.Bytes()
+}
+
+func TestSkipDatum(t *testing.T) {
+	schema := MustParseSchema(skipTestSchemaRaw)
+	data := skipTestData()
+	decoders := map[string]Decoder{
+		"buffer": NewBinaryDecoder(data),
+		"reader": NewBinaryDecoderReader(iotest.OneByteReader(bytes.NewReader(data))),
+		"bufio":  NewBinaryDecoderReader(bufio.NewReader(bytes.NewReader(data))),
+	}
+	for name, dec := range decoders {
+		if err := SkipDatum(schema, dec); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		next, err := dec.ReadLong()
+		assert(t, err, nil)
+		assert(t, next, int64(42))
+	}
+
+	// a truncated datum
+	err := SkipDatum(schema, NewBinaryDecoder(data[:len(data)-10]))
+	assert(t, err, ErrUnexpectedEOF)
+	err = SkipDatum(schema, NewBinaryDecoderReader(bytes.NewReader(data[:len(data)-10])))
+	assert(t, err, ErrUnexpectedEOF)
+}
+
+func TestSkipDatum_prepared(t *testing.T) {
+	dec := NewBinaryDecoder(skipTestData())
+	assert(t, SkipDatum(Prepare(MustParseSchema(skipTestSchemaRaw)), dec), nil)
+	next, err := dec.ReadLong()
+	assert(t, err, nil)
+	assert(t, next, int64(42))
+}
+
+func TestSkipDatum_decoder(t *testing.T) {
+	// decoders which can't skip read the values instead
+	schema := MustParseSchema(jsonTestSchemaRaw)
+	dec := NewJSONDecoder(schema, strings.NewReader(jsonTestDatum+`{"id":7,"count":0,"ok":false,"ratio":0,"score":0,"name":"",`+
+		`"payload":"","nothing":null,"hash":"ab","kind":"A","tags":[],"empty":[],"attrs":{},"opt":null,"none":null,"link":null}`))
+	assert(t, SkipDatum(schema, dec), nil)
+	record := NewGenericRecord(schema)
+	assert(t, NewGenericDatumReader().SetSchema(schema).Read(record, dec), nil)
+	assert(t, record.Get("id"), int64(7))
+}
+
+func TestDatumProjector_skipRemovedFields(t *testing.T) {
+	writerSchema := MustParseSchema(skipTestSchemaRaw)
+	readerSchema := MustParseSchema(`{"type": "record", "name": "Skipped", "fields": [
+		{"name": "d", "type": "double"}
+	]}`)
+	projector, err := NewDatumProjector(readerSchema, writerSchema)
+	assert(t, err, nil)
+
+	data := skipTestData()
+	var record *GenericRecord
+	dec := NewBinaryDecoder(data)
+	assert(t, projector.Read(&record, dec), nil)
+	assert(t, record.Get("d"), 1.5)
+
+	var specific struct{ D float64 }
+	dec = NewBinaryDecoder(data)
+	assert(t, projector.Read(&specific, dec), nil)
+	assert(t, specific.D, 1.5)
+	next, err := dec.ReadLong()
+	assert(t, err, nil)
+	assert(t, next, int64(42))
+}
@@ -1,9 +1,8 @@
 package avro
 
 import (
-	"encoding/binary"
+	"bufio"
 	"io"
-	"math"
 )
 
 // Encoder is an interface that provides low-level support for serializing Avro values.
@@ -53,9 +52,27 @@ type Encoder interface {
 	WriteRaw([]byte)
 }
 
+// BufferedEncoder is an Encoder which buffers what is written and records
+// the first error returned by its output, after which nothing more is
+// written. Encoder methods don't return errors, so Err or Flush must be
+// checked once a datum has been written. DatumWriters return the error of
+// any Encoder with an Err method.
+type BufferedEncoder interface {
+	Encoder
+
+	// Err returns the first error returned by the output, if any.
+	Err() error
+
+	// Flush writes any buffered data to the output and returns the first error, if any.
+	Flush() error
+}
+
 // BinaryEncoder implements Encoder and provides low-level support for serializing Avro values.
 type binaryEncoder struct {
-	buffer io.Writer
+	buffer   io.Writer
+	buffered *bufio.Writer // the same as buffer for a BufferedEncoder
+	err      error
+	scratch  [10]byte // for encoding numbers without allocating
 }
 
 // NewBinaryEncoder creates a new BinaryEncoder that will write to a given io.Writer.
@@ -67,6 +84,36 @@ func newBinaryEncoder(buffer io.Writer) *binaryEncoder {
 	return &binaryEncoder{buffer: buffer}
 }
 
+// NewBufferedBinaryEncoder creates a BufferedEncoder for the binary encoding
+// which writes to output in chunks of at least 4096 bytes.
+func NewBufferedBinaryEncoder(output io.Writer) BufferedEncoder {
+	return newBufferedBinaryEncoder(output)
+}
+
+func newBufferedBinaryEncoder(output io.Writer) *binaryEncoder {
+	buffered := bufio.NewWriter(output)
+	return &binaryEncoder{buffer: buffered, buffered: buffered}
+}
+
+// Err returns the first error returned by the output, if any.
+func (be *binaryEncoder) Err() error {
+	return be.err
+}
+
+// Flush writes any buffered data to the output and returns the first error, if any.
+func (be *binaryEncoder) Flush() error {
+	if be.err == nil && be.buffered != nil {
+		be.err = be.buffered.Flush()
+	}
+	return be.err
+}
+
+func (be *binaryEncoder) write(x []byte) {
+	if be.err == nil {
+		_, be.err = be.buffer.Write(x)
+	}
+}
+
 // WriteNull writes a null value. Doesn't actually do anything in this implementation.
 func (be *binaryEncoder) WriteNull(_ interface{}) {
 	//do nothing
@@ -79,52 +126,50 @@ var encBoolFalse = []byte{0x00}
 // WriteBoolean writes a boolean value.
 func (be *binaryEncoder) WriteBoolean(x bool) {
 	if x {
-		_, _ = be.buffer.Write(encBoolTrue)
+		be.write(encBoolTrue)
 	} else {
-		_, _ = be.buffer.Write(encBoolFalse)
+		be.write(encBoolFalse)
 	}
 }
 
 // WriteInt writes an int value.
 func (be *binaryEncoder) WriteInt(x int32) {
-	_, _ = be.buffer.Write(be.encodeVarint32(x))
+	be.write(be.encodeVarint32(x))
 }
 
 // WriteLong writes a long value.
 func (be *binaryEncoder) WriteLong(x int64) {
-	_, _ = be.buffer.Write(be.encodeVarint64(x))
+	be.write(be.encodeVarint64(x))
 }
 
 // WriteFloat writes a float value.
 func (be *binaryEncoder) WriteFloat(x float32) {
-	bytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(bytes, math.Float32bits(x))
-	_, _ = be.buffer.Write(bytes)
+	be.write(appendFloat32(be.scratch[:0], x))
 }
 
 // WriteDouble writes a double value.
 func (be *binaryEncoder) WriteDouble(x float64) {
-	bytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(bytes, math.Float64bits(x))
-	_, _ = be.buffer.Write(bytes)
+	be.write(appendFloat64(be.scratch[:0], x))
 }
 
 // WriteRaw writes raw bytes to this Encoder.
 func (be *binaryEncoder) WriteRaw(x []byte) {
-	_, _ = be.buffer.Write(x)
+	be.write(x)
 }
 
 // WriteBytes writes a bytes value.
 func (be *binaryEncoder) WriteBytes(x []byte) {
 	be.WriteLong(int64(len(x)))
-	_, _ = be.buffer.Write(x)
+	be.write(x)
 }
 
 // WriteString writes a string value.
 func (be *binaryEncoder) WriteString(x string) {
 	be.WriteLong(int64(len(x)))
 	// call writers that happen to provide WriteString to avoid extra byte allocations for a copy of a string when possible.
-	_, _ = io.WriteString(be.buffer, x)
+	if be.err == nil {
+		_, be.err = io.WriteString(be.buffer, x)
+	}
 }
 
 // WriteArrayStart should be called when starting to serialize an array providing it with a number of items in
@@ -155,36 +200,12 @@ func (be *binaryEncoder) writeItemCount(count int64) {
 	be.WriteLong(count)
 }
 
+// encodeVarint32 encodes into the scratch space of the encoder, valid until the next write.
 func (be *binaryEncoder) encodeVarint32(n int32) []byte {
-	var buf [5]byte
-	ux := uint32(n) << 1
-	if n < 0 {
-		ux = ^ux
-	}
-	i := 0
-	for ux >= 0x80 {
-		buf[i] = byte(ux) | 0x80
-		ux >>= 7
-		i++
-	}
-	buf[i] = byte(ux)
-
-	return buf[0 : i+1]
+	return appendVarint32(be.scratch[:0], n)
 }
 
+// encodeVarint64 encodes into the scratch space of the encoder, valid until the next write.
 func (be *binaryEncoder) encodeVarint64(x int64) []byte {
-	var buf [10]byte
-	ux := uint64(x) << 1
-	if x < 0 {
-		ux = ^ux
-	}
-	i := 0
-	for ux >= 0x80 {
-		buf[i] = byte(ux) | 0x80
-		ux >>= 7
-		i++
-	}
-	buf[i] = byte(ux)
-
-	return buf[0 : i+1]
+	return appendVarint64(be.scratch[:0], x)
 }
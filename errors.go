@@ -30,9 +30,15 @@ var ErrInvalidLong = errors.New("Invalid long value")
 // Happens when given value to decode as string has either negative or undecodable length.
 var ErrInvalidStringLength = errors.New("Invalid string length")
 
+// Happens when an array or map block has a negative size in bytes.
+var ErrInvalidBlockSize = errors.New("Invalid block size")
+
 // Indicates the given file to decode does not correspond to Avro data file format.
 var ErrNotAvroFile = errors.New("Not an Avro data file")
 
+// Happens when seeking within a data file whose input does not support seeking.
+var ErrNotSeekable = errors.New("Input is not seekable")
+
 // Happens when trying to read next block without finishing the previous one.
 var ErrBlockNotFinished = errors.New("Block read is unfinished")
 
@@ -51,6 +57,15 @@ var ErrInvalidSchema = errors.New("Invalid schema")
 // Happens when a datum reader has no set schema.
 var ErrSchemaNotSet = errors.New("Schema not set")
 
+// Happens when a SchemaStore has no schema of a fingerprint or a SchemaRegistry has no schema of an ID.
+var ErrSchemaNotFound = errors.New("Schema not found")
+
+// Happens when decoding a message which doesn't start with the header of the single-object encoding.
+var ErrNotSingleObject = errors.New("Not a single-object encoded message")
+
+// Happens when decoding a message which doesn't start with the header of the Confluent wire format.
+var ErrNotConfluentMessage = errors.New("Not a Confluent wire format message")
+
 // Specify a custom error message for indicating which necessary field in the struct is missing.
 func NewFieldDoesNotExistError(field string) error {
 	return errors.New(fmt.Sprintf("Field does not exist: [%v]", field))
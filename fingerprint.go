@@ -0,0 +1,49 @@
+package avro
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+)
+
+// Fingerprint64 returns the CRC-64-AVRO fingerprint (a Rabin fingerprint) of
+// the Parsing Canonical Form of a schema, the 64-bit fingerprint of the
+// specification which identifies the writer schema of single-object
+// encoded messages.
+func Fingerprint64(schema Schema) uint64 {
+	return fingerprint64([]byte(ParsingCanonicalForm(schema)))
+}
+
+// FingerprintMD5 returns the MD5 fingerprint of the Parsing Canonical Form of a schema.
+func FingerprintMD5(schema Schema) [16]byte {
+	return md5.Sum([]byte(ParsingCanonicalForm(schema)))
+}
+
+// FingerprintSHA256 returns the SHA-256 fingerprint of the Parsing Canonical
+// Form of a schema, which is also what Schema.Fingerprint returns.
+func FingerprintSHA256(schema Schema) [32]byte {
+	return sha256.Sum256([]byte(ParsingCanonicalForm(schema)))
+}
+
+// CRC-64-AVRO fingerprint of empty input, which is also the polynomial of the CRC.
+const crc64AvroEmpty = 0xc15d213aa4d7a795
+
+var crc64AvroTable = func() *[256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (crc64AvroEmpty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return &table
+}()
+
+// fingerprint64 computes the CRC-64-AVRO fingerprint of data as defined by the specification.
+func fingerprint64(data []byte) uint64 {
+	fp := uint64(crc64AvroEmpty)
+	for _, b := range data {
+		fp = (fp >> 8) ^ crc64AvroTable[byte(fp)^b]
+	}
+	return fp
+}
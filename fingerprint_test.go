@@ -0,0 +1,57 @@
+package avro
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestFingerprint64(t *testing.T) {
+	// test vectors of the specification
+	vectors := map[string]int64{
+		`"null"`:    7195948357588979594,
+		`"boolean"`: -6970731678124411036,
+		`"int"`:     8247732601305521295,
+		`"long"`:    -3434872931120570953,
+		`"float"`:   5583340709985441680,
+		`"double"`:  -8181574048448539266,
+		`"bytes"`:   5746618253357095269,
+		`"string"`:  -8142146995180207161,
+	}
+	for canonical, fingerprint := range vectors {
+		assert(t, int64(fingerprint64([]byte(canonical))), fingerprint)
+		assert(t, int64(Fingerprint64(MustParseSchema(canonical))), fingerprint)
+	}
+	assert(t, fingerprint64(nil), uint64(crc64AvroEmpty))
+
+	// fingerprints of complex schemas computed by other implementations
+	schemas := map[string]uint64{
+		`{"type":"record", "name":"test", "namespace": "org.hamba.avro", "doc": "docs", "fields":[{"name": "field", "type": "int"}]}`: 0xaf3030f01c9976da,
+		`{"type":"enum", "name":"test", "namespace": "org.hamba.avro", "symbols":["TEST"]}`:                                           0x0cb0a2a65f9608d1,
+		`{"type":"fixed", "name":"test", "namespace": "org.hamba.avro", "size": 12}`:                                                  0x017c1f7fa76da0a1,
+		`{"type":"array", "items": "int"}`: 0x522b814fc963b4be,
+		`{"type":"map", "values": "int"}`:  0xdb39e2c2534c8973,
+		`["null", "int"]`:                  0xd51cc0922b46b1d7,
+	}
+	for schema, fingerprint := range schemas {
+		assert(t, Fingerprint64(MustParseSchema(schema)), fingerprint)
+	}
+}
+
+func TestFingerprintMD5AndSHA256(t *testing.T) {
+	schema := MustParseSchema(`{"type": "null"}`)
+	md5 := FingerprintMD5(schema)
+	assert(t, hex.EncodeToString(md5[:]), "9b41ef67651c18488a8b08bb67c75699")
+	sha256 := FingerprintSHA256(schema)
+	assert(t, hex.EncodeToString(sha256[:]), "f072cbec3bf8841871d4284230c5e983dc211a56837aed862487148f947d1a1f")
+	fingerprint, err := schema.Fingerprint()
+	assert(t, err, nil)
+	assert(t, *fingerprint, Fingerprint(sha256))
+
+	// schemas which only differ in attributes that don't matter for reading
+	// and writing data have the same fingerprints
+	a := MustParseSchema(`{"type": "record", "name": "R", "namespace": "x", "doc": "a", "fields": [{"name": "f", "type": "int", "default": 1}]}`)
+	b := MustParseSchema(`{"name": "x.R", "type": "record", "fields": [{"type": "int", "name": "f", "aliases": ["g"]}]}`)
+	assert(t, Fingerprint64(a), Fingerprint64(b))
+	assert(t, FingerprintMD5(a), FingerprintMD5(b))
+	assert(t, FingerprintSHA256(a), FingerprintSHA256(b))
+}
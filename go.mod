@@ -1,3 +1,10 @@
 module github.com/amient/avro
 
 go 1.12
+
+require (
+	github.com/dsnet/compress v0.0.1
+	github.com/golang/snappy v0.0.2
+	github.com/klauspost/compress v1.11.7
+	github.com/ulikunitz/xz v0.5.9
+)
@@ -0,0 +1,383 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// JSONDecoder implements Decoder for the JSON encoding of the Avro
+// specification, see JSONEncoder. It reads datums of the schema it was
+// created with, which must be the schema the JSON was written with, one
+// JSON value after the other, e.g. one per line as written by JSONEncoder
+// or JsonEncoder of the Java implementation. Record fields may come in any
+// order and missing fields are read as their default, if they have one.
+type JSONDecoder struct {
+	schema Schema
+	input  *json.Decoder
+	stack  []jsonDecodeFrame
+}
+
+type jsonDecodeFrame struct {
+	kind      int
+	schema    Schema
+	namespace string // enclosing the schema
+	value     interface{}
+}
+
+// NewJSONDecoder creates a JSONDecoder reading datums of the given schema from input.
+func NewJSONDecoder(schema Schema, input io.Reader) *JSONDecoder {
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+	return &JSONDecoder{schema: schema, input: dec}
+}
+
+// ReadNull reads a null value.
+func (d *JSONDecoder) ReadNull() (interface{}, error) { return nil, nil }
+
+// ReadBoolean reads a boolean value.
+func (d *JSONDecoder) ReadBoolean() (bool, error) {
+	frame, err := d.value("boolean", Boolean)
+	if err != nil {
+		return false, err
+	}
+	if x, ok := frame.value.(bool); ok {
+		return x, nil
+	}
+	return false, d.invalid(frame)
+}
+
+// ReadInt reads an int value, or the index of a union branch.
+func (d *JSONDecoder) ReadInt() (int32, error) {
+	x, err := d.readLong("int", Int)
+	if err == nil && (x < math.MinInt32 || x > math.MaxInt32) {
+		return 0, fmt.Errorf("JSONDecoder: Int %d out of range", x)
+	}
+	return int32(x), err
+}
+
+// ReadLong reads a long value, or the index of a union branch.
+func (d *JSONDecoder) ReadLong() (int64, error) {
+	return d.readLong("long", Long)
+}
+
+func (d *JSONDecoder) readLong(what string, t int) (int64, error) {
+	frame, err := d.value(what, t, Union)
+	if err != nil {
+		return 0, err
+	}
+	if s, ok := frame.schema.(*UnionSchema); ok {
+		return d.readUnion(s, frame)
+	}
+	switch x := frame.value.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return n, nil
+		}
+	case float64:
+		// defaults of fields
+		if x == math.Trunc(x) {
+			return int64(x), nil
+		}
+	}
+	return 0, d.invalid(frame)
+}
+
+// readUnion finds the branch of a union value and reads its value next.
+func (d *JSONDecoder) readUnion(s *UnionSchema, frame jsonDecodeFrame) (int64, error) {
+	value := frame.value
+	if value == nil {
+		for i, t := range s.Types {
+			if t.Type() == Null {
+				return int64(i), nil
+			}
+		}
+		return 0, fmt.Errorf("JSONDecoder: Null is not a branch of %s", s)
+	}
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for i, t := range s.Types {
+			t = jsonResolve(t)
+			if branch, ok := wrapped[jsonFullName(t, frame.namespace)]; ok {
+				d.push(jsonDecodeFrame{kind: jsonValue, schema: t, namespace: frame.namespace, value: branch})
+				return int64(i), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("JSONDecoder: Invalid value of %s: %v", s, value)
+}
+
+// ReadFloat reads a float value.
+func (d *JSONDecoder) ReadFloat() (float32, error) {
+	x, err := d.readDouble("float", Float)
+	return float32(x), err
+}
+
+// ReadDouble reads a double value.
+func (d *JSONDecoder) ReadDouble() (float64, error) {
+	return d.readDouble("double", Double)
+}
+
+func (d *JSONDecoder) readDouble(what string, t int) (float64, error) {
+	frame, err := d.value(what, t)
+	if err != nil {
+		return 0, err
+	}
+	switch x := frame.value.(type) {
+	case json.Number:
+		if f, err := strconv.ParseFloat(string(x), 64); err == nil {
+			return f, nil
+		}
+	case float64:
+		// defaults of fields
+		return x, nil
+	case string:
+		switch x {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+	}
+	return 0, d.invalid(frame)
+}
+
+// ReadBytes reads a bytes value written as a string of ISO-8859-1 characters.
+func (d *JSONDecoder) ReadBytes() ([]byte, error) {
+	frame, err := d.value("bytes", Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return d.bytes(frame)
+}
+
+// ReadString reads a string value or the key of a map entry.
+func (d *JSONDecoder) ReadString() (string, error) {
+	frame, err := d.next()
+	if err != nil {
+		return "", err
+	}
+	if frame.kind == jsonMapKey {
+		return frame.value.(string), nil
+	}
+	if err := d.expect(frame, "string", String); err != nil {
+		return "", err
+	}
+	if x, ok := frame.value.(string); ok {
+		return x, nil
+	}
+	return "", d.invalid(frame)
+}
+
+// ReadEnum reads the index of an enum symbol.
+func (d *JSONDecoder) ReadEnum() (int32, error) {
+	frame, err := d.value("enum", Enum)
+	if err != nil {
+		return 0, err
+	}
+	if symbol, ok := frame.value.(string); ok {
+		for i, s := range frame.schema.(*EnumSchema).Symbols {
+			if s == symbol {
+				return int32(i), nil
+			}
+		}
+	}
+	return 0, d.invalid(frame)
+}
+
+// ReadArrayStart reads the number of items of an array, all of which are
+// in a single block.
+func (d *JSONDecoder) ReadArrayStart() (int64, error) {
+	frame, err := d.value("array", Array)
+	if err != nil {
+		return 0, err
+	}
+	items, ok := frame.value.([]interface{})
+	if !ok {
+		return 0, d.invalid(frame)
+	}
+	if len(items) > 0 {
+		d.push(jsonDecodeFrame{kind: jsonArrayEnd})
+	}
+	itemSchema := jsonResolve(frame.schema.(*ArraySchema).Items)
+	for i := len(items) - 1; i >= 0; i-- {
+		d.push(jsonDecodeFrame{kind: jsonValue, schema: itemSchema, namespace: frame.namespace, value: items[i]})
+	}
+	return int64(len(items)), nil
+}
+
+// ArrayNext ends an array after its only block.
+func (d *JSONDecoder) ArrayNext() (int64, error) {
+	return 0, d.end(jsonArrayEnd, "array")
+}
+
+// ReadMapStart reads the number of entries of a map, all of which are in a
+// single block. The keys are read in sorted order.
+func (d *JSONDecoder) ReadMapStart() (int64, error) {
+	frame, err := d.value("map", Map)
+	if err != nil {
+		return 0, err
+	}
+	entries, ok := frame.value.(map[string]interface{})
+	if !ok {
+		return 0, d.invalid(frame)
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		d.push(jsonDecodeFrame{kind: jsonMapEnd})
+	}
+	valueSchema := jsonResolve(frame.schema.(*MapSchema).Values)
+	for i := len(keys) - 1; i >= 0; i-- {
+		d.push(jsonDecodeFrame{kind: jsonValue, schema: valueSchema, namespace: frame.namespace, value: entries[keys[i]]})
+		d.push(jsonDecodeFrame{kind: jsonMapKey, value: keys[i]})
+	}
+	return int64(len(keys)), nil
+}
+
+// MapNext ends a map after its only block.
+func (d *JSONDecoder) MapNext() (int64, error) {
+	return 0, d.end(jsonMapEnd, "map")
+}
+
+// ReadFixed reads a fixed value written as a string of ISO-8859-1 characters.
+func (d *JSONDecoder) ReadFixed(x []byte) error {
+	frame, err := d.value("fixed", Fixed)
+	if err != nil {
+		return err
+	}
+	value, err := d.bytes(frame)
+	if err != nil {
+		return err
+	}
+	if len(value) != len(x) {
+		return fmt.Errorf("JSONDecoder: Invalid size %d of fixed %s, expected %d", len(value), GetFullName(frame.schema), len(x))
+	}
+	copy(x, value)
+	return nil
+}
+
+func (d *JSONDecoder) bytes(frame jsonDecodeFrame) ([]byte, error) {
+	s, ok := frame.value.(string)
+	if !ok {
+		return nil, d.invalid(frame)
+	}
+	x := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, d.invalid(frame)
+		}
+		x = append(x, byte(r))
+	}
+	return x, nil
+}
+
+// next returns what is read next, reading the next datum if the previous
+// one is complete. Records are expanded into their fields and null values,
+// which are never read by a DatumReader, are skipped.
+func (d *JSONDecoder) next() (jsonDecodeFrame, error) {
+	for {
+		if len(d.stack) == 0 {
+			var datum interface{}
+			if err := d.input.Decode(&datum); err != nil {
+				if err != io.EOF {
+					err = fmt.Errorf("JSONDecoder: %s", err.Error())
+				}
+				return jsonDecodeFrame{}, err
+			}
+			d.push(jsonDecodeFrame{kind: jsonValue, schema: jsonResolve(d.schema), value: datum})
+		}
+		frame := d.pop()
+		if frame.kind != jsonValue {
+			return frame, nil
+		}
+		switch s := frame.schema.(type) {
+		case *NullSchema:
+			if frame.value != nil {
+				return frame, d.invalid(frame)
+			}
+		case *RecordSchema:
+			fields, ok := frame.value.(map[string]interface{})
+			if !ok {
+				return frame, d.invalid(frame)
+			}
+			namespace := jsonNamespace(s, frame.namespace)
+			for i := len(s.Fields) - 1; i >= 0; i-- {
+				field := s.Fields[i]
+				value, ok := fields[field.Name]
+				if !ok {
+					if field.Default == nil && !field.hasDefault {
+						return frame, fmt.Errorf("JSONDecoder: Missing field %q of record %s", field.Name, GetFullName(s))
+					}
+					value = jsonDefault(jsonResolve(field.Type), namespace, field.Default)
+				}
+				d.push(jsonDecodeFrame{kind: jsonValue, schema: jsonResolve(field.Type), namespace: namespace, value: value})
+			}
+		default:
+			return frame, nil
+		}
+	}
+}
+
+// value returns the next value, which must be of one of the given types.
+func (d *JSONDecoder) value(what string, types ...int) (jsonDecodeFrame, error) {
+	frame, err := d.next()
+	if err != nil {
+		return frame, err
+	}
+	return frame, d.expect(frame, what, types...)
+}
+
+func (d *JSONDecoder) expect(frame jsonDecodeFrame, what string, types ...int) error {
+	if frame.kind == jsonValue {
+		for _, t := range types {
+			if frame.schema.Type() == t {
+				return nil
+			}
+		}
+		return fmt.Errorf("JSONDecoder: Unexpected read of %s, the next value is of %s", what, frame.schema)
+	}
+	return fmt.Errorf("JSONDecoder: Unexpected read of %s", what)
+}
+
+func (d *JSONDecoder) end(kind int, what string) error {
+	frame, err := d.next()
+	if err == nil && frame.kind != kind {
+		err = fmt.Errorf("JSONDecoder: Unexpected end of %s", what)
+	}
+	return err
+}
+
+func (d *JSONDecoder) invalid(frame jsonDecodeFrame) error {
+	return fmt.Errorf("JSONDecoder: Invalid value of %s: %v", frame.schema, frame.value)
+}
+
+func (d *JSONDecoder) push(frame jsonDecodeFrame) {
+	d.stack = append(d.stack, frame)
+}
+
+func (d *JSONDecoder) pop() jsonDecodeFrame {
+	frame := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return frame
+}
+
+// jsonDefault converts the default of a field to the JSON encoding, which
+// only differs for unions as their default is a bare value of the first branch.
+func jsonDefault(s Schema, namespace string, value interface{}) interface{} {
+	if union, ok := s.(*UnionSchema); ok && len(union.Types) > 0 {
+		first := jsonResolve(union.Types[0])
+		if first.Type() == Null {
+			return nil
+		}
+		return map[string]interface{}{jsonFullName(first, namespace): value}
+	}
+	return value
+}
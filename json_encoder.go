@@ -0,0 +1,449 @@
+package avro
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONEncoder implements Encoder for the JSON encoding of the Avro
+// specification, interoperable with JsonEncoder of the Java implementation:
+// records are objects, union branches other than null are wrapped in an
+// object keyed by the full name of the branch's type, e.g. {"string": "a"}
+// or {"ns.Record": {...}}, where named types without a namespace are in the
+// namespace of the enclosing record, enums are their symbols and bytes and
+// fixed values are strings with one character per byte, i.e. ISO-8859-1.
+//
+// As the JSON encoding depends on the schema and not only on the calls made
+// by a DatumWriter, the encoder follows the schema it was created with.
+// Every datum written is followed by a newline and written to the output as
+// soon as it is complete. A call which doesn't fit the schema or a write
+// error stops the encoder, see Err.
+type JSONEncoder struct {
+	schema Schema
+	output io.Writer
+	buf    []byte
+	stack  []jsonEncodeFrame
+	err    error
+}
+
+type jsonEncodeFrame struct {
+	kind      int
+	schema    Schema // the value, array items or map values
+	namespace string // enclosing the schema
+	name      string // of a field
+	remaining int64  // items left in the current array or map block
+	count     int64  // items written so far to an array or map
+}
+
+const (
+	jsonValue = iota
+	jsonField
+	jsonRecordEnd
+	jsonUnionEnd
+	jsonArray
+	jsonMap
+	jsonMapKey
+	jsonArrayEnd
+	jsonMapEnd
+)
+
+// NewJSONEncoder creates a JSONEncoder writing datums of the given schema to output.
+func NewJSONEncoder(schema Schema, output io.Writer) *JSONEncoder {
+	return &JSONEncoder{schema: schema, output: output}
+}
+
+// Err returns the first error encountered by the encoder, if any.
+// Nothing is written after an error.
+func (e *JSONEncoder) Err() error {
+	return e.err
+}
+
+// WriteNull doesn't do anything, null values are written as the schema requires.
+func (e *JSONEncoder) WriteNull(_ interface{}) {
+	//do nothing
+}
+
+// WriteBoolean writes a boolean value.
+func (e *JSONEncoder) WriteBoolean(x bool) {
+	if e.value("boolean", Boolean) {
+		e.pop()
+		e.buf = strconv.AppendBool(e.buf, x)
+		e.advance()
+	}
+}
+
+// WriteInt writes an int value, which may also be the index of a union branch or an enum symbol.
+func (e *JSONEncoder) WriteInt(x int32) {
+	e.writeLong(int64(x), "int")
+}
+
+// WriteLong writes a long value, which may also be the index of a union branch or an enum symbol.
+func (e *JSONEncoder) WriteLong(x int64) {
+	e.writeLong(x, "long")
+}
+
+func (e *JSONEncoder) writeLong(x int64, what string) {
+	if !e.value(what, Int, Long, Union, Enum) {
+		return
+	}
+	frame := e.pop()
+	switch s := frame.schema.(type) {
+	case *UnionSchema:
+		if x < 0 || x >= int64(len(s.Types)) {
+			e.fail(fmt.Errorf("JSONEncoder: Invalid union index %d for %s", x, s))
+			return
+		}
+		branch := jsonResolve(s.Types[x])
+		if branch.Type() != Null {
+			e.buf = append(e.buf, '{')
+			e.buf = appendJSONString(e.buf, jsonFullName(branch, frame.namespace))
+			e.buf = append(e.buf, ':')
+			e.push(jsonEncodeFrame{kind: jsonUnionEnd})
+		}
+		e.push(jsonEncodeFrame{kind: jsonValue, schema: branch, namespace: frame.namespace})
+	case *EnumSchema:
+		if x < 0 || x >= int64(len(s.Symbols)) {
+			e.fail(fmt.Errorf("JSONEncoder: Invalid symbol index %d for enum %s", x, GetFullName(s)))
+			return
+		}
+		e.buf = appendJSONString(e.buf, s.Symbols[x])
+	default:
+		e.buf = strconv.AppendInt(e.buf, x, 10)
+	}
+	e.advance()
+}
+
+// WriteFloat writes a float value.
+func (e *JSONEncoder) WriteFloat(x float32) {
+	if e.value("float", Float, Double) {
+		e.pop()
+		e.buf = appendJSONFloat(e.buf, float64(x), 32)
+		e.advance()
+	}
+}
+
+// WriteDouble writes a double value.
+func (e *JSONEncoder) WriteDouble(x float64) {
+	if e.value("double", Double, Float) {
+		e.pop()
+		e.buf = appendJSONFloat(e.buf, x, 64)
+		e.advance()
+	}
+}
+
+// WriteBytes writes a bytes value as a string of ISO-8859-1 characters.
+func (e *JSONEncoder) WriteBytes(x []byte) {
+	if e.value("bytes", Bytes) {
+		e.pop()
+		e.buf = appendJSONBytes(e.buf, x)
+		e.advance()
+	}
+}
+
+// WriteString writes a string value, which may also be the key of a map entry.
+func (e *JSONEncoder) WriteString(x string) {
+	if e.err != nil {
+		return
+	}
+	e.start()
+	if top := e.top(); top.kind == jsonMapKey {
+		e.pop()
+		if top.count > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = appendJSONString(e.buf, x)
+		e.buf = append(e.buf, ':')
+		e.advance()
+		return
+	}
+	if e.value("string", String) {
+		e.pop()
+		e.buf = appendJSONString(e.buf, x)
+		e.advance()
+	}
+}
+
+// WriteArrayStart starts an array with a block of the given number of items.
+func (e *JSONEncoder) WriteArrayStart(count int64) {
+	if e.value("array start", Array) {
+		frame := e.pop()
+		s := frame.schema.(*ArraySchema)
+		e.buf = append(e.buf, '[')
+		e.push(jsonEncodeFrame{kind: jsonArray, schema: jsonResolve(s.Items), namespace: frame.namespace, remaining: count})
+		e.advance()
+	}
+}
+
+// WriteArrayNext starts the next block of items of an array or ends it if count is 0.
+func (e *JSONEncoder) WriteArrayNext(count int64) {
+	e.writeNext(count, jsonArray, Array, '[', ']')
+}
+
+// WriteMapStart starts a map with a block of the given number of entries.
+func (e *JSONEncoder) WriteMapStart(count int64) {
+	if e.value("map start", Map) {
+		frame := e.pop()
+		s := frame.schema.(*MapSchema)
+		e.buf = append(e.buf, '{')
+		e.push(jsonEncodeFrame{kind: jsonMap, schema: jsonResolve(s.Values), namespace: frame.namespace, remaining: count})
+		e.advance()
+	}
+}
+
+// WriteMapNext starts the next block of entries of a map or ends it if count is 0.
+func (e *JSONEncoder) WriteMapNext(count int64) {
+	e.writeNext(count, jsonMap, Map, '{', '}')
+}
+
+func (e *JSONEncoder) writeNext(count int64, kind int, schemaType int, open, close byte) {
+	if e.err != nil {
+		return
+	}
+	e.start()
+	top := e.top()
+	switch {
+	case top.kind == kind && top.remaining == 0:
+		// the end of a block
+		if count > 0 {
+			top.remaining = count
+		} else {
+			e.pop()
+			e.buf = append(e.buf, close)
+		}
+	case top.kind == jsonValue && top.schema.Type() == schemaType && count == 0:
+		// an empty array or map has no start
+		e.pop()
+		e.buf = append(e.buf, open, close)
+	default:
+		e.unexpected("block end")
+		return
+	}
+	e.advance()
+}
+
+// WriteRaw writes the value of a fixed as a string of ISO-8859-1 characters.
+func (e *JSONEncoder) WriteRaw(x []byte) {
+	if !e.value("fixed", Fixed) {
+		return
+	}
+	if s := e.top().schema.(*FixedSchema); len(x) != s.Size {
+		e.fail(fmt.Errorf("JSONEncoder: Invalid size %d for fixed %s of size %d", len(x), GetFullName(s), s.Size))
+		return
+	}
+	e.pop()
+	e.buf = appendJSONBytes(e.buf, x)
+	e.advance()
+}
+
+// start begins the next datum if the previous one is complete.
+func (e *JSONEncoder) start() {
+	if len(e.stack) == 0 {
+		e.push(jsonEncodeFrame{kind: jsonValue, schema: jsonResolve(e.schema)})
+		e.advance()
+	}
+}
+
+// value checks that the next value of the datum is of one of the given types.
+func (e *JSONEncoder) value(what string, types ...int) bool {
+	if e.err != nil {
+		return false
+	}
+	e.start()
+	if top := e.top(); top.kind == jsonValue {
+		for _, t := range types {
+			if top.schema.Type() == t {
+				return true
+			}
+		}
+	}
+	e.unexpected(what)
+	return false
+}
+
+// advance writes everything which doesn't need a call of the DatumWriter,
+// up to the next value, and the datum once it is complete.
+func (e *JSONEncoder) advance() {
+	for len(e.stack) > 0 {
+		top := e.top()
+		switch top.kind {
+		case jsonValue:
+			switch s := top.schema.(type) {
+			case *NullSchema:
+				e.pop()
+				e.buf = append(e.buf, "null"...)
+			case *RecordSchema:
+				e.pop()
+				e.buf = append(e.buf, '{')
+				e.push(jsonEncodeFrame{kind: jsonRecordEnd})
+				namespace := jsonNamespace(s, top.namespace)
+				for i := len(s.Fields) - 1; i >= 0; i-- {
+					e.push(jsonEncodeFrame{kind: jsonValue, schema: jsonResolve(s.Fields[i].Type), namespace: namespace})
+					e.push(jsonEncodeFrame{kind: jsonField, name: s.Fields[i].Name, count: int64(i)})
+				}
+			default:
+				return
+			}
+		case jsonField:
+			e.pop()
+			if top.count > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = appendJSONString(e.buf, top.name)
+			e.buf = append(e.buf, ':')
+		case jsonRecordEnd, jsonUnionEnd:
+			e.pop()
+			e.buf = append(e.buf, '}')
+		case jsonArray:
+			if top.remaining == 0 {
+				return
+			}
+			if top.count > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			top.remaining--
+			top.count++
+			e.push(jsonEncodeFrame{kind: jsonValue, schema: top.schema, namespace: top.namespace})
+		case jsonMap:
+			if top.remaining == 0 {
+				return
+			}
+			top.remaining--
+			top.count++
+			e.push(jsonEncodeFrame{kind: jsonValue, schema: top.schema, namespace: top.namespace})
+			e.push(jsonEncodeFrame{kind: jsonMapKey, count: top.count - 1})
+		default:
+			return
+		}
+	}
+
+	e.buf = append(e.buf, '\n')
+	if _, err := e.output.Write(e.buf); err != nil {
+		e.fail(err)
+	}
+	e.buf = e.buf[:0]
+}
+
+func (e *JSONEncoder) top() *jsonEncodeFrame {
+	return &e.stack[len(e.stack)-1]
+}
+
+func (e *JSONEncoder) push(frame jsonEncodeFrame) {
+	e.stack = append(e.stack, frame)
+}
+
+func (e *JSONEncoder) pop() jsonEncodeFrame {
+	frame := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return frame
+}
+
+func (e *JSONEncoder) unexpected(what string) {
+	top := e.top()
+	switch top.kind {
+	case jsonValue:
+		e.fail(fmt.Errorf("JSONEncoder: Unexpected %s, expected a value of %s", what, top.schema))
+	case jsonMapKey:
+		e.fail(fmt.Errorf("JSONEncoder: Unexpected %s, expected a map key", what))
+	default:
+		e.fail(fmt.Errorf("JSONEncoder: Unexpected %s, expected the end of a block", what))
+	}
+}
+
+func (e *JSONEncoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// jsonResolve returns the definition of a recursive reference to a record
+// and the record of a prepared one, see Prepare.
+func jsonResolve(s Schema) Schema {
+	switch r := s.(type) {
+	case *RecursiveSchema:
+		return r.Actual
+	case *preparedRecordSchema:
+		return &r.RecordSchema
+	}
+	return s
+}
+
+// jsonFullName returns the full name of a union branch. Named types without
+// a namespace of their own are in the namespace enclosing them.
+func jsonFullName(s Schema, namespace string) string {
+	var name, ownNamespace string
+	switch s := s.(type) {
+	case *RecordSchema:
+		name, ownNamespace = s.Name, s.Namespace
+	case *EnumSchema:
+		name, ownNamespace = s.Name, s.Namespace
+	case *FixedSchema:
+		name, ownNamespace = s.Name, s.Namespace
+	default:
+		return s.GetName()
+	}
+	if ownNamespace != "" {
+		namespace = ownNamespace
+	}
+	return getFullName(name, namespace)
+}
+
+// jsonNamespace returns the namespace enclosing the fields of a record, the
+// namespace of its full name.
+func jsonNamespace(s *RecordSchema, namespace string) string {
+	fullName := jsonFullName(s, namespace)
+	if i := strings.LastIndexByte(fullName, '.'); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
+
+// appendJSONFloat writes NaN and infinities as strings, as Java does.
+func appendJSONFloat(buf []byte, x float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(x):
+		return append(buf, `"NaN"`...)
+	case math.IsInf(x, 1):
+		return append(buf, `"Infinity"`...)
+	case math.IsInf(x, -1):
+		return append(buf, `"-Infinity"`...)
+	}
+	return strconv.AppendFloat(buf, x, 'g', -1, bitSize)
+}
+
+func appendJSONBytes(buf []byte, x []byte) []byte {
+	buf = append(buf, '"')
+	for _, b := range x {
+		buf = appendJSONRune(buf, rune(b))
+	}
+	return append(buf, '"')
+}
+
+func appendJSONString(buf []byte, x string) []byte {
+	buf = append(buf, '"')
+	for _, r := range x {
+		buf = appendJSONRune(buf, r)
+	}
+	return append(buf, '"')
+}
+
+func appendJSONRune(buf []byte, r rune) []byte {
+	switch r {
+	case '"', '\\':
+		return append(buf, '\\', byte(r))
+	case '\n':
+		return append(buf, '\\', 'n')
+	case '\r':
+		return append(buf, '\\', 'r')
+	case '\t':
+		return append(buf, '\\', 't')
+	}
+	if r < 0x20 || r == 0x7f {
+		return append(buf, fmt.Sprintf(`\u%04x`, r)...)
+	}
+	var encoded [utf8.UTFMax]byte
+	return append(buf, encoded[:utf8.EncodeRune(encoded[:], r)]...)
+}
@@ -0,0 +1,168 @@
+package avro
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+const jsonTestSchemaRaw = `{"type": "record", "name": "Event", "namespace": "test", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "count", "type": "int"},
+	{"name": "ok", "type": "boolean"},
+	{"name": "ratio", "type": "float"},
+	{"name": "score", "type": "double"},
+	{"name": "name", "type": "string"},
+	{"name": "payload", "type": "bytes"},
+	{"name": "nothing", "type": "null"},
+	{"name": "hash", "type": {"type": "fixed", "name": "Hash", "namespace": "test", "size": 2}},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+	{"name": "tags", "type": {"type": "array", "items": "string"}},
+	{"name": "empty", "type": {"type": "array", "items": "int"}},
+	{"name": "attrs", "type": {"type": "map", "values": ["null", "long"]}},
+	{"name": "opt", "type": ["null", "string", "test.Hash"]},
+	{"name": "none", "type": ["null", "string"]},
+	{"name": "link", "type": ["null", {"type": "record", "name": "Link", "namespace": "test", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "next", "type": ["null", "test.Link"]}
+	]}]}
+]}`
+
+// a datum in the JSON encoding, as written by JSONEncoder
+const jsonTestDatum = `{"id":1234567890123,"count":-5,"ok":true,"ratio":1.5,"score":"NaN","name":"tab\t\"quoted\" é",` +
+	`"payload":"\u0000ÿ\u0010","nothing":null,"hash":"ab","kind":"B","tags":["x","y"],"empty":[],` +
+	`"attrs":{"k":{"long":7}},"opt":{"test.Hash":"cd"},"none":null,` +
+	`"link":{"test.Link":{"id":2,"next":{"test.Link":{"id":3,"next":null}}}}}`
+
+func TestJSONEncoding_generic(t *testing.T) {
+	schema := MustParseSchema(jsonTestSchemaRaw)
+	dec := NewJSONDecoder(schema, strings.NewReader(jsonTestDatum+"\n"+jsonTestDatum))
+	reader := NewGenericDatumReader().SetSchema(schema)
+	writer := NewGenericDatumWriter().SetSchema(schema)
+	out := &bytes.Buffer{}
+	enc := NewJSONEncoder(schema, out)
+	for i := 0; i < 2; i++ {
+		record := NewGenericRecord(schema)
+		assert(t, reader.Read(record, dec), nil)
+		assert(t, record.Get("payload"), []byte{0, 0xff, 0x10})
+		assert(t, record.Get("id"), int64(1234567890123))
+		assert(t, writer.Write(record, enc), nil)
+		assert(t, enc.Err(), nil)
+	}
+	assert(t, reader.Read(NewGenericRecord(schema), dec), io.EOF)
+	assert(t, out.String(), jsonTestDatum+"\n"+jsonTestDatum+"\n")
+}
+
+func TestJSONEncoding_specific(t *testing.T) {
+	schema := MustParseSchema(primitiveSchemaRaw)
+	in := randomPrimitiveObject()
+	in.StringField = "ünïcode \"quoted\"\n" // random strings may not be valid UTF-8
+	buf := &bytes.Buffer{}
+	enc := NewJSONEncoder(schema, buf)
+	assert(t, NewSpecificDatumWriter().SetSchema(schema).Write(in, enc), nil)
+	assert(t, enc.Err(), nil)
+
+	out := &primitive{}
+	assert(t, NewSpecificDatumReader().SetSchema(schema).Read(out, NewJSONDecoder(schema, buf)), nil)
+	assert(t, out, in)
+}
+
+func TestJSONEncoding_prepared(t *testing.T) {
+	schema := Prepare(MustParseSchema(jsonTestSchemaRaw))
+	record := NewGenericRecord(schema)
+	assert(t, NewGenericDatumReader().SetSchema(schema).Read(record, NewJSONDecoder(schema, strings.NewReader(jsonTestDatum))), nil)
+	out := &bytes.Buffer{}
+	enc := NewJSONEncoder(schema, out)
+	assert(t, NewGenericDatumWriter().SetSchema(schema).Write(record, enc), nil)
+	assert(t, enc.Err(), nil)
+	assert(t, out.String(), jsonTestDatum+"\n")
+
+	schema = Prepare(MustParseSchema(primitiveSchemaRaw))
+	in := randomPrimitiveObject()
+	in.StringField = "x"
+	out.Reset()
+	enc = NewJSONEncoder(schema, out)
+	assert(t, NewSpecificDatumWriter().SetSchema(schema).Write(in, enc), nil)
+	assert(t, enc.Err(), nil)
+	actual := &primitive{}
+	assert(t, NewSpecificDatumReader().SetSchema(schema).Read(actual, NewJSONDecoder(schema, out)), nil)
+	assert(t, actual, in)
+}
+
+func TestJSONEncoding_namespaces(t *testing.T) {
+	// named types without a namespace of their own are in the enclosing one
+	schema := MustParseSchema(`{"type": "record", "name": "A", "namespace": "n", "fields": [
+		{"name": "b", "type": ["null", {"type": "record", "name": "B", "fields": [
+			{"name": "x", "type": "int"},
+			{"name": "f", "type": ["null", {"type": "fixed", "name": "F", "namespace": "m", "size": 1}]}
+		]}]},
+		{"name": "c", "type": ["null", "B"]},
+		{"name": "d", "type": [{"type": "fixed", "name": "D", "size": 1}, "null"], "default": "X"}
+	]}`)
+	datum := `{"b":{"n.B":{"x":1,"f":{"m.F":"y"}}},"c":{"n.B":{"x":2,"f":null}},"d":{"n.D":"X"}}`
+	reader := NewGenericDatumReader().SetSchema(schema)
+	writer := NewGenericDatumWriter().SetSchema(schema)
+	for _, input := range []string{datum, strings.Replace(datum, `,"d":{"n.D":"X"}`, "", 1)} {
+		record := NewGenericRecord(schema)
+		assert(t, reader.Read(record, NewJSONDecoder(schema, strings.NewReader(input))), nil)
+		out := &bytes.Buffer{}
+		enc := NewJSONEncoder(schema, out)
+		assert(t, writer.Write(record, enc), nil)
+		assert(t, enc.Err(), nil)
+		assert(t, out.String(), datum+"\n")
+	}
+
+	err := reader.Read(NewGenericRecord(schema), NewJSONDecoder(schema, strings.NewReader(`{"b":{"B":{"x":1,"f":null}},"c":null}`)))
+	assert(t, err != nil, true)
+}
+
+func TestJSONDecoder_fields(t *testing.T) {
+	schema := MustParseSchema(`{"type": "record", "name": "R", "fields": [
+		{"name": "a", "type": "int"},
+		{"name": "b", "type": "string", "default": "x"},
+		{"name": "c", "type": ["long", "null"], "default": 3}
+	]}`)
+	reader := NewGenericDatumReader().SetSchema(schema)
+
+	record := NewGenericRecord(schema)
+	assert(t, reader.Read(record, NewJSONDecoder(schema, strings.NewReader(`{"c": null, "a": 1}`))), nil)
+	assert(t, record.Get("a"), int32(1))
+	assert(t, record.Get("b"), "x")
+	assert(t, record.Get("c"), nil)
+
+	record = NewGenericRecord(schema)
+	assert(t, reader.Read(record, NewJSONDecoder(schema, strings.NewReader(`{"a": 1}`))), nil)
+	assert(t, record.Get("c"), int64(3))
+
+	err := reader.Read(NewGenericRecord(schema), NewJSONDecoder(schema, strings.NewReader(`{"b": "y"}`)))
+	assert(t, err != nil, true)
+	err = reader.Read(NewGenericRecord(schema), NewJSONDecoder(schema, strings.NewReader(`{"a": 1, "c": {"int": 2}}`)))
+	assert(t, err != nil, true)
+}
+
+func TestJSONDecoder_nullDefault(t *testing.T) {
+	schema := MustParseSchema(`{"type": "record", "name": "R", "fields": [
+		{"name": "a", "type": "int"},
+		{"name": "n", "type": ["null", "string"], "default": null}
+	]}`)
+	record := NewGenericRecord(schema)
+	reader := NewGenericDatumReader().SetSchema(schema)
+	assert(t, reader.Read(record, NewJSONDecoder(schema, strings.NewReader(`{"a": 1}`))), nil)
+	assert(t, record.Get("a"), int32(1))
+	assert(t, record.Get("n"), nil)
+
+	// a field without a default is still required
+	err := reader.Read(NewGenericRecord(schema), NewJSONDecoder(schema, strings.NewReader(`{"n": null}`)))
+	assert(t, err != nil, true)
+}
+
+func TestJSONEncoder_invalid(t *testing.T) {
+	schema := MustParseSchema(`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "int"}]}`)
+	out := &bytes.Buffer{}
+	enc := NewJSONEncoder(schema, out)
+	enc.WriteString("a")
+	assert(t, enc.Err() != nil, true)
+	enc.WriteInt(1)
+	assert(t, out.Len(), 0)
+}
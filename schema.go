@@ -1,7 +1,6 @@
 package avro
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -188,8 +187,8 @@ func (*StringSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*StringSchema) Validate(v reflect.Value) bool {
-	_, ok := dereference(v).Interface().(string)
-	return ok
+	v = elementOf(v)
+	return v.IsValid() && v.Type() == stringType
 }
 
 // Canonical Schema
@@ -335,7 +334,7 @@ func (*IntSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*IntSchema) Validate(v reflect.Value) bool {
-	return reflect.TypeOf(dereference(v).Interface()).Kind() == reflect.Int32
+	return kindOf(v) == reflect.Int32
 }
 
 // Canonical Schema
@@ -423,7 +422,7 @@ func (*LongSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*LongSchema) Validate(v reflect.Value) bool {
-	return reflect.TypeOf(dereference(v).Interface()).Kind() == reflect.Int64
+	return kindOf(v) == reflect.Int64
 }
 
 // Canonical Schema
@@ -501,7 +500,7 @@ func (*FloatSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*FloatSchema) Validate(v reflect.Value) bool {
-	return reflect.TypeOf(dereference(v).Interface()).Kind() == reflect.Float32
+	return kindOf(v) == reflect.Float32
 }
 
 // Canonical Schema
@@ -585,7 +584,7 @@ func (*DoubleSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*DoubleSchema) Validate(v reflect.Value) bool {
-	return reflect.TypeOf(dereference(v).Interface()).Kind() == reflect.Float64
+	return kindOf(v) == reflect.Float64
 }
 
 // Canonical Schema
@@ -661,7 +660,7 @@ func (*BooleanSchema) Prop(key string) (interface{}, bool) {
 
 // Validate checks whether the given value is writeable to this schema.
 func (*BooleanSchema) Validate(v reflect.Value) bool {
-	return reflect.TypeOf(dereference(v).Interface()).Kind() == reflect.Bool
+	return kindOf(v) == reflect.Bool
 }
 
 // Canonical Schema
@@ -962,12 +961,12 @@ func (s *RecordSchema) Validate(v reflect.Value) bool {
 	if v.Kind() != reflect.Struct || !v.CanAddr() || !v.CanInterface() {
 		return false
 	}
-	rec, ok := v.Interface().(GenericRecord)
-	if !ok {
+	if v.Type() != genericRecordType {
 		// This is not a generic record and is likely a specific record. Hence
 		// use the basic check.
-		return v.Kind() == reflect.Struct
+		return true
 	}
+	rec := v.Interface().(GenericRecord)
 
 	fieldCount := 0
 	for key, val := range rec.fields {
@@ -1063,6 +1062,10 @@ type SchemaField struct {
 	Type       Schema      `json:"type,omitempty"`
 	Aliases    []string    `json:"aliases,omitempty"`
 	Properties map[string]interface{}
+
+	// whether the field declares a default, which tells a null default
+	// from none
+	hasDefault bool
 }
 
 // Returns representation considering whether the same type was already declared
@@ -1074,6 +1077,7 @@ func (s *SchemaField) withRegistry(registry map[string]Schema) *SchemaField {
 		Doc:        s.Doc,
 		Properties: s.Properties,
 		Type:       s.Type.withRegistry(registry),
+		hasDefault: s.hasDefault,
 	}
 }
 
@@ -1575,7 +1579,7 @@ func (s *MapSchema) Canonical() (*CanonicalSchema, error) {
 	if vc, err := s.Values.Canonical(); err != nil {
 		return nil, err
 	} else {
-		return &CanonicalSchema{Type: "array", Values: vc}, nil
+		return &CanonicalSchema{Type: "map", Values: vc}, nil
 	}
 }
 
@@ -2029,6 +2033,7 @@ func parseSchemaField(i interface{}, registry map[string]Schema, namespace strin
 		}
 		schemaField.Type = fieldType
 		if def, exists := v[schemaDefaultField]; exists {
+			schemaField.hasDefault = true
 			switch def.(type) {
 			case float64:
 				// JSON treats all numbers as float64 by default
@@ -2160,17 +2165,31 @@ func dereference(v reflect.Value) reflect.Value {
 	return v
 }
 
-func calculateSchemaFingerprint(s Schema) (*Fingerprint, error) {
-	if canonical, err := s.Canonical(); err != nil {
-		return nil, err
-	} else if bytes, err := canonical.MarshalJSON(); err != nil {
-		return nil, err
-	} else {
-		f := Fingerprint(sha256.Sum256(bytes))
-		return &f, nil
+var stringType = reflect.TypeOf("")
+var genericRecordType = reflect.TypeOf(GenericRecord{})
+
+// elementOf looks through a pointer and an interface to the value held by v,
+// like Interface does but without allocating a copy of the value.
+func elementOf(v reflect.Value) reflect.Value {
+	v = dereference(v)
+	if v.Kind() == reflect.Interface {
+		return v.Elem()
 	}
+	return v
+}
+
+// kindOf returns the kind of the value held by v, see elementOf.
+func kindOf(v reflect.Value) reflect.Kind {
+	return elementOf(v).Kind()
+}
+
+func calculateSchemaFingerprint(s Schema) (*Fingerprint, error) {
+	f := Fingerprint(FingerprintSHA256(s))
+	return &f, nil
 }
 
+// CanonicalSchema is a simplified form of a schema, it is not the Parsing
+// Canonical Form of the specification, see ParsingCanonicalForm.
 type CanonicalSchema struct {
 	Name    string                  `json:"name,omitempty"`
 	Type    string                  `json:"type,omitempty"`
@@ -2179,7 +2198,7 @@ type CanonicalSchema struct {
 	Items   *CanonicalSchema        `json:"items,omitempty"`
 	Values  *CanonicalSchema        `json:"values,omitempty"`
 	Size    int                     `json:"size,omitempty"`
-	Types   []*CanonicalSchema      `json:"size,omit"`
+	Types   []*CanonicalSchema      `json:"types,omitempty"`
 }
 
 type CanonicalSchemaField struct {
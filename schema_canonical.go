@@ -0,0 +1,127 @@
+package avro
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParsingCanonicalForm returns the Parsing Canonical Form of a schema as
+// defined by the specification, which is the same for all schemas that read
+// and write data the same way: only the attributes which matter for that are
+// kept, in a fixed order, names are fully qualified, named types are only
+// defined where they first occur and the JSON has no whitespace. Fingerprints
+// of schemas are computed from it, see Fingerprint64.
+func ParsingCanonicalForm(schema Schema) string {
+	c := canonicalWriter{seen: make(map[string]bool)}
+	c.write(schema, "")
+	return string(c.buf)
+}
+
+type canonicalWriter struct {
+	buf  []byte
+	seen map[string]bool // full names of the named types already defined
+}
+
+// write appends the canonical form of a schema enclosed in the given namespace.
+func (c *canonicalWriter) write(schema Schema, namespace string) {
+	switch s := schema.(type) {
+	case *RecordSchema:
+		c.writeRecord(s, namespace)
+	case *preparedRecordSchema:
+		c.writeRecord(&s.RecordSchema, namespace)
+	case *RecursiveSchema:
+		c.writeRecord(s.Actual, namespace)
+	case *refSchema:
+		if c.seen[s.Type_] {
+			c.buf = appendJSONString(c.buf, s.Type_)
+		} else {
+			c.write(s.Ref, namespace)
+		}
+	case *EnumSchema:
+		if _, ok := c.writeName(s.Name, s.Namespace, namespace); !ok {
+			return
+		}
+		c.buf = append(c.buf, `,"type":"enum","symbols":[`...)
+		for i, symbol := range s.Symbols {
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			c.buf = appendJSONString(c.buf, symbol)
+		}
+		c.buf = append(c.buf, "]}"...)
+	case *FixedSchema:
+		if _, ok := c.writeName(s.Name, s.Namespace, namespace); !ok {
+			return
+		}
+		c.buf = append(c.buf, `,"type":"fixed","size":`...)
+		c.buf = strconv.AppendInt(c.buf, int64(s.Size), 10)
+		c.buf = append(c.buf, '}')
+	case *ArraySchema:
+		c.buf = append(c.buf, `{"type":"array","items":`...)
+		c.write(s.Items, namespace)
+		c.buf = append(c.buf, '}')
+	case *MapSchema:
+		c.buf = append(c.buf, `{"type":"map","values":`...)
+		c.write(s.Values, namespace)
+		c.buf = append(c.buf, '}')
+	case *UnionSchema:
+		c.buf = append(c.buf, '[')
+		for i, t := range s.Types {
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			c.write(t, namespace)
+		}
+		c.buf = append(c.buf, ']')
+	default:
+		// primitive types
+		c.buf = appendJSONString(c.buf, schema.GetName())
+	}
+}
+
+func (c *canonicalWriter) writeRecord(s *RecordSchema, namespace string) {
+	fullName, ok := c.writeName(s.Name, s.Namespace, namespace)
+	if !ok {
+		return
+	}
+	// nested types are enclosed in the namespace of the record's full name
+	namespace = ""
+	if i := strings.LastIndexByte(fullName, '.'); i >= 0 {
+		namespace = fullName[:i]
+	}
+	c.buf = append(c.buf, `,"type":"record","fields":[`...)
+	for i, field := range s.Fields {
+		if i > 0 {
+			c.buf = append(c.buf, ',')
+		}
+		c.buf = append(c.buf, `{"name":`...)
+		c.buf = appendJSONString(c.buf, field.Name)
+		c.buf = append(c.buf, `,"type":`...)
+		c.write(field.Type, namespace)
+		c.buf = append(c.buf, '}')
+	}
+	c.buf = append(c.buf, "]}"...)
+}
+
+// writeName appends the start of the definition of a named type up to its
+// full name. If the type has been defined before, only its full name is
+// appended as a reference and ok is false.
+func (c *canonicalWriter) writeName(name, ownNamespace, namespace string) (fullName string, ok bool) {
+	fullName = name
+	if !strings.ContainsRune(name, '.') {
+		if ownNamespace != "" {
+			namespace = ownNamespace
+		}
+		if namespace != "" {
+			fullName = namespace + "." + name
+		}
+	}
+	if c.seen[fullName] {
+		c.buf = appendJSONString(c.buf, fullName)
+		return fullName, false
+	}
+	c.seen[fullName] = true
+	c.buf = append(c.buf, `{"name":`...)
+	c.buf = appendJSONString(c.buf, fullName)
+	return fullName, true
+}
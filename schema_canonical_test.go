@@ -0,0 +1,72 @@
+package avro
+
+import "testing"
+
+func TestParsingCanonicalForm(t *testing.T) {
+	// test vectors of the specification, from share/test/data/schema-tests.txt
+	vectors := []struct{ schema, canonical string }{
+		{`"null"`, `"null"`},
+		{`{"type":"null"}`, `"null"`},
+		{`"boolean"`, `"boolean"`},
+		{`{"type":"boolean"}`, `"boolean"`},
+		{`"int"`, `"int"`},
+		{`{"type":"int"}`, `"int"`},
+		{`"long"`, `"long"`},
+		{`{"type":"long"}`, `"long"`},
+		{`"float"`, `"float"`},
+		{`{"type":"float"}`, `"float"`},
+		{`"double"`, `"double"`},
+		{`{"type":"double"}`, `"double"`},
+		{`"bytes"`, `"bytes"`},
+		{`{"type":"bytes"}`, `"bytes"`},
+		{`"string"`, `"string"`},
+		{`{"type":"string"}`, `"string"`},
+		{`[  ]`, `[]`},
+		{`[ "int"  ]`, `["int"]`},
+		{`[ "int" , {"type":"boolean"} ]`, `["int","boolean"]`},
+		{`{"fields":[], "type":"record", "name":"foo"}`, `{"name":"foo","type":"record","fields":[]}`},
+		{`{"fields":[], "type":"record", "name":"foo", "namespace":"x.y"}`, `{"name":"x.y.foo","type":"record","fields":[]}`},
+		{`{"fields":[], "type":"record", "name":"a.b.foo", "namespace":"x.y"}`, `{"name":"a.b.foo","type":"record","fields":[]}`},
+		{`{"fields":[], "type":"record", "name":"foo", "doc":"Useful info"}`, `{"name":"foo","type":"record","fields":[]}`},
+		{`{"fields":[], "type":"record", "name":"foo", "aliases":["foo","bar"]}`, `{"name":"foo","type":"record","fields":[]}`},
+		{`{"fields":[], "type":"record", "name":"foo", "doc":"foo", "aliases":["foo","bar"]}`, `{"name":"foo","type":"record","fields":[]}`},
+		{`{"fields":[{"type":{"type":"boolean"}, "name":"f1"}], "type":"record", "name":"foo"}`,
+			`{"name":"foo","type":"record","fields":[{"name":"f1","type":"boolean"}]}`},
+		{`{"fields":[{"type":"boolean", "aliases":[], "name":"f1", "default":true},
+			{"order":"descending","name":"f2","doc":"Hello","type":"int"}], "type":"record", "name":"foo"}`,
+			`{"name":"foo","type":"record","fields":[{"name":"f1","type":"boolean"},{"name":"f2","type":"int"}]}`},
+		{`{"type":"enum", "name":"foo", "symbols":["A1"]}`, `{"name":"foo","type":"enum","symbols":["A1"]}`},
+		{`{"namespace":"x.y.z", "type":"enum", "name":"foo", "doc":"foo bar", "symbols":["A1", "A2"]}`,
+			`{"name":"x.y.z.foo","type":"enum","symbols":["A1","A2"]}`},
+		{`{"name":"foo","type":"fixed","size":15}`, `{"name":"foo","type":"fixed","size":15}`},
+		{`{"namespace":"x.y.z", "type":"fixed", "name":"foo", "doc":"foo bar", "size":32}`,
+			`{"name":"x.y.z.foo","type":"fixed","size":32}`},
+		{`{ "items":{"type":"null"}, "type":"array"}`, `{"type":"array","items":"null"}`},
+		{`{ "values":"string", "type":"map"}`, `{"type":"map","values":"string"}`},
+		{`  {"name":"PigValue","type":"record",
+			"fields":[{"name":"value", "type":["null", "int", "long", "PigValue"]}]}`,
+			`{"name":"PigValue","type":"record","fields":[{"name":"value","type":["null","int","long","PigValue"]}]}`},
+		{`"\u0069\u006e\u0074"`, `"int"`},
+		{`{"type":"enum","symbols":["\u0047\u006f","\u0041\u0076\u0072\u006f"],"name":"\u0046\u006f\u006f"}`,
+			`{"name":"Foo","type":"enum","symbols":["Go","Avro"]}`},
+		{`{"fields":[{"name":"hi","type":"int"}], "type":"record", "name":"Foo", "namespace":"x.y"}`,
+			`{"name":"x.y.Foo","type":"record","fields":[{"name":"hi","type":"int"}]}`},
+		// nested types inherit the namespace and are only defined once
+		{`{"type":"record","name":"foo","namespace":"bar","fields":[
+			{"name":"baz","type":{"type":"record","name":"baz","fields":[{"name":"hi","type":"int"}]}},
+			{"name":"bye","type":["null","baz"]}]}`,
+			`{"name":"bar.foo","type":"record","fields":[{"name":"baz","type":{"name":"bar.baz","type":"record","fields":[{"name":"hi","type":"int"}]}},{"name":"bye","type":["null","bar.baz"]}]}`},
+		{`{"type":"record","name":"foo","namespace":"x.y","fields":[
+			{"name":"e","type":{"type":"enum","name":"bar","symbols":["A1","A2"]}},
+			{"name":"f","type":{"type":"fixed","name":"a.b.bar","size":32}}]}`,
+			`{"name":"x.y.foo","type":"record","fields":[{"name":"e","type":{"name":"x.y.bar","type":"enum","symbols":["A1","A2"]}},{"name":"f","type":{"name":"a.b.bar","type":"fixed","size":32}}]}`},
+	}
+	for _, vector := range vectors {
+		schema, err := ParseSchema(vector.schema)
+		if err != nil {
+			t.Fatalf("%s: %v", vector.schema, err)
+		}
+		assert(t, ParsingCanonicalForm(schema), vector.canonical)
+		assert(t, ParsingCanonicalForm(Prepare(schema)), vector.canonical)
+	}
+}
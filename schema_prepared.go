@@ -85,6 +85,8 @@ func (job *prepareJob) prepareRecordSchema(input *RecordSchema) *preparedRecordS
 			Doc:     field.Doc,
 			Default: field.Default,
 			Type:    job.prepare(field.Type),
+
+			hasDefault: field.hasDefault,
 		})
 	}
 	return output
@@ -0,0 +1,252 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaRegistry resolves the IDs of writer schemas of Confluent wire format
+// messages, see ConfluentSerializer. Implementations must be safe for
+// concurrent use.
+type SchemaRegistry interface {
+	// GetSchema returns the schema of the given ID or ErrSchemaNotFound if
+	// the registry doesn't know it.
+	GetSchema(id int) (Schema, error)
+
+	// Register registers a schema under a subject and returns its ID. A
+	// schema which is already registered keeps its ID.
+	Register(subject string, schema Schema) (int, error)
+}
+
+// SchemaRegistryError is returned when a schema registry rejects a request.
+type SchemaRegistryError struct {
+	StatusCode int    // HTTP status code of the response
+	ErrorCode  int    // error code of the registry, e.g. 40403 for an unknown schema
+	Message    string // error message of the registry
+}
+
+func (e *SchemaRegistryError) Error() string {
+	return fmt.Sprintf("Schema registry error %d (HTTP %d): %s", e.ErrorCode, e.StatusCode, e.Message)
+}
+
+// Error code of the Confluent schema registry for unknown schemas.
+const schemaRegistryErrSchemaNotFound = 40403
+
+// Content type of the requests and responses of the Confluent schema registry.
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// Request and response bodies of the REST API of the Confluent schema registry.
+type schemaRegistrySchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+type schemaRegistryID struct {
+	ID int `json:"id"`
+}
+
+type schemaRegistryErrorBody struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// schemaRegistryText returns the text under which a registry keeps a
+// schema: its JSON with sorted keys and without whitespace. Unlike the
+// Parsing Canonical Form it keeps every attribute, so like the Confluent
+// schema registry, schemas which only differ in e.g. a default or a doc are
+// registered as different schemas.
+func schemaRegistryText(rawSchema []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(rawSchema))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+// marshalSchemaRegistryText returns the text under which a registry keeps a schema.
+func marshalSchemaRegistryText(schema Schema) (string, error) {
+	if schema == nil {
+		return "", ErrSchemaNotSet
+	}
+	rawSchema, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return schemaRegistryText(rawSchema)
+}
+
+// SchemaRegistryClient is a SchemaRegistry backed by the REST API of a
+// Confluent schema registry. It doesn't cache anything, wrap it with
+// NewCachedSchemaRegistry to only request every schema once.
+type SchemaRegistryClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewSchemaRegistryClient creates a SchemaRegistryClient for the registry at
+// baseURL, e.g. "http://localhost:8081". Requests are sent with client, which
+// may add authentication in its Transport. If client is nil,
+// http.DefaultClient is used.
+func NewSchemaRegistryClient(baseURL string, client *http.Client) *SchemaRegistryClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SchemaRegistryClient{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// GetSchema fetches the schema of the given ID from the registry.
+func (c *SchemaRegistryClient) GetSchema(id int) (Schema, error) {
+	var body schemaRegistrySchema
+	if err := c.do("GET", "/schemas/ids/"+strconv.Itoa(id), nil, &body); err != nil {
+		if registryErr, ok := err.(*SchemaRegistryError); ok && registryErr.ErrorCode == schemaRegistryErrSchemaNotFound {
+			return nil, ErrSchemaNotFound
+		}
+		return nil, err
+	}
+	if body.SchemaType != "" && body.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("SchemaRegistryClient: Schema %d is of type %s", id, body.SchemaType)
+	}
+	schema, err := ParseSchema(body.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("SchemaRegistryClient: Cannot parse schema %d: %s", id, err.Error())
+	}
+	return schema, nil
+}
+
+// Register registers a schema under a subject in the registry.
+func (c *SchemaRegistryClient) Register(subject string, schema Schema) (int, error) {
+	text, err := marshalSchemaRegistryText(schema)
+	if err != nil {
+		return 0, err
+	}
+	request, err := json.Marshal(schemaRegistrySchema{Schema: text})
+	if err != nil {
+		return 0, err
+	}
+	var body schemaRegistryID
+	if err := c.do("POST", "/subjects/"+url.PathEscape(subject)+"/versions", request, &body); err != nil {
+		return 0, err
+	}
+	return body.ID, nil
+}
+
+// do sends a request to the registry and decodes the JSON response into v.
+func (c *SchemaRegistryClient) do(method, path string, request []byte, v interface{}) error {
+	var body io.Reader
+	if request != nil {
+		body = bytes.NewReader(request)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if request != nil {
+		req.Header.Set("Content-Type", schemaRegistryContentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errorBody schemaRegistryErrorBody
+		if json.Unmarshal(response, &errorBody) != nil || errorBody.Message == "" {
+			errorBody.Message = strings.TrimSpace(string(response))
+		}
+		return &SchemaRegistryError{StatusCode: resp.StatusCode, ErrorCode: errorBody.ErrorCode, Message: errorBody.Message}
+	}
+	if err := json.Unmarshal(response, v); err != nil {
+		return fmt.Errorf("SchemaRegistryClient: Invalid response to %s %s: %s", method, path, err.Error())
+	}
+	return nil
+}
+
+// cachedSchemaRegistry is the SchemaRegistry returned by NewCachedSchemaRegistry.
+type cachedSchemaRegistry struct {
+	registry SchemaRegistry
+
+	mu      sync.RWMutex
+	schemas map[int]Schema
+	ids     map[cachedSchemaRegistryKey]int
+}
+
+type cachedSchemaRegistryKey struct {
+	subject string
+	text    string // see schemaRegistryText
+}
+
+// NewCachedSchemaRegistry wraps a SchemaRegistry so that every schema is
+// fetched and every schema is registered under a subject only once. Schemas
+// never change once registered, so the cache is never invalidated. Errors
+// aren't cached.
+func NewCachedSchemaRegistry(registry SchemaRegistry) SchemaRegistry {
+	return &cachedSchemaRegistry{
+		registry: registry,
+		schemas:  make(map[int]Schema),
+		ids:      make(map[cachedSchemaRegistryKey]int),
+	}
+}
+
+func (r *cachedSchemaRegistry) GetSchema(id int) (Schema, error) {
+	r.mu.RLock()
+	schema, ok := r.schemas[id]
+	r.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := r.registry.GetSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.schemas[id] = schema
+	r.mu.Unlock()
+	return schema, nil
+}
+
+func (r *cachedSchemaRegistry) Register(subject string, schema Schema) (int, error) {
+	text, err := marshalSchemaRegistryText(schema)
+	if err != nil {
+		return 0, err
+	}
+	key := cachedSchemaRegistryKey{subject: subject, text: text}
+	r.mu.RLock()
+	id, ok := r.ids[key]
+	r.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	id, err = r.registry.Register(subject, schema)
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	r.ids[key] = id
+	if _, ok := r.schemas[id]; !ok {
+		r.schemas[id] = schema
+	}
+	r.mu.Unlock()
+	return id, nil
+}
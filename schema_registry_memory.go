@@ -0,0 +1,153 @@
+package avro
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemorySchemaRegistry is a SchemaRegistry holding the schemas registered in
+// it. Like the Confluent schema registry, it numbers schemas from 1 and gives
+// a schema the same ID under every subject. Schemas are told apart by their
+// whole JSON, not just by what matters for reading and writing data, so a
+// schema which only changes e.g. a default gets a new ID.
+//
+// It's also an http.Handler serving the parts of the REST API of the
+// Confluent schema registry which SchemaRegistryClient uses, so it can stand
+// in for a real registry in tests, e.g. with httptest.NewServer:
+//
+//	GET  /schemas/ids/{id}
+//	POST /subjects/{subject}/versions
+type MemorySchemaRegistry struct {
+	mu       sync.RWMutex
+	schemas  []Schema
+	texts    []string       // of the schemas, see schemaRegistryText
+	ids      map[string]int // by text
+	subjects map[string][]int
+}
+
+// NewMemorySchemaRegistry creates an empty MemorySchemaRegistry.
+func NewMemorySchemaRegistry() *MemorySchemaRegistry {
+	return &MemorySchemaRegistry{
+		ids:      make(map[string]int),
+		subjects: make(map[string][]int),
+	}
+}
+
+// GetSchema returns the schema of the given ID.
+func (r *MemorySchemaRegistry) GetSchema(id int) (Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id < 1 || id > len(r.schemas) {
+		return nil, ErrSchemaNotFound
+	}
+	return r.schemas[id-1], nil
+}
+
+// schemaText returns the text of the schema of the given ID.
+func (r *MemorySchemaRegistry) schemaText(id int) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id < 1 || id > len(r.texts) {
+		return "", false
+	}
+	return r.texts[id-1], true
+}
+
+// Register registers a schema under a subject and returns its ID.
+func (r *MemorySchemaRegistry) Register(subject string, schema Schema) (int, error) {
+	text, err := marshalSchemaRegistryText(schema)
+	if err != nil {
+		return 0, err
+	}
+	return r.register(subject, schema, text), nil
+}
+
+func (r *MemorySchemaRegistry) register(subject string, schema Schema, text string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.ids[text]
+	if !ok {
+		r.schemas = append(r.schemas, schema)
+		r.texts = append(r.texts, text)
+		id = len(r.schemas)
+		r.ids[text] = id
+	}
+	for _, registered := range r.subjects[subject] {
+		if registered == id {
+			return id
+		}
+	}
+	r.subjects[subject] = append(r.subjects[subject], id)
+	return id
+}
+
+// Versions returns the IDs of the schemas registered under a subject, in the
+// order in which they were registered.
+func (r *MemorySchemaRegistry) Versions(subject string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]int(nil), r.subjects[subject]...)
+}
+
+// ServeHTTP serves the REST API of the registry.
+func (r *MemorySchemaRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// split the escaped path, subjects may contain escaped slashes
+	parts := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+	switch {
+	case req.Method == "GET" && len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, err := strconv.Atoi(parts[2])
+		text, ok := "", false
+		if err == nil {
+			text, ok = r.schemaText(id)
+		}
+		if !ok {
+			writeSchemaRegistryError(w, http.StatusNotFound, schemaRegistryErrSchemaNotFound, "Schema not found")
+			return
+		}
+		writeSchemaRegistryResponse(w, http.StatusOK, schemaRegistrySchema{Schema: text})
+	case req.Method == "POST" && len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions":
+		var body schemaRegistrySchema
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeSchemaRegistryError(w, http.StatusBadRequest, 400, "Invalid request body: "+err.Error())
+			return
+		}
+		if body.SchemaType != "" && body.SchemaType != "AVRO" {
+			writeSchemaRegistryError(w, http.StatusUnprocessableEntity, 42201, "Unsupported schema type "+body.SchemaType)
+			return
+		}
+		schema, err := ParseSchema(body.Schema)
+		if err != nil {
+			writeSchemaRegistryError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema: "+err.Error())
+			return
+		}
+		// the schema is kept as it was sent, with all of its attributes
+		text, err := schemaRegistryText([]byte(body.Schema))
+		if err != nil {
+			writeSchemaRegistryError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema: "+err.Error())
+			return
+		}
+		subject, err := url.PathUnescape(parts[1])
+		if err != nil {
+			writeSchemaRegistryError(w, http.StatusNotFound, 404, "HTTP 404 Not Found")
+			return
+		}
+		id := r.register(subject, schema, text)
+		writeSchemaRegistryResponse(w, http.StatusOK, schemaRegistryID{ID: id})
+	default:
+		writeSchemaRegistryError(w, http.StatusNotFound, 404, "HTTP 404 Not Found")
+	}
+}
+
+func writeSchemaRegistryResponse(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", schemaRegistryContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeSchemaRegistryError(w http.ResponseWriter, statusCode, errorCode int, message string) {
+	writeSchemaRegistryResponse(w, statusCode, schemaRegistryErrorBody{ErrorCode: errorCode, Message: message})
+}
@@ -0,0 +1,182 @@
+package avro
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSchemaRegistryClient(t *testing.T) {
+	memory := NewMemorySchemaRegistry()
+	server := httptest.NewServer(memory)
+	defer server.Close()
+	client := NewSchemaRegistryClient(server.URL+"/", nil)
+
+	schema := MustParseSchema(singleObjectWriterSchemaRaw)
+	id, err := client.Register("users/value", schema)
+	assert(t, err, nil)
+	assert(t, id, 1)
+	assert(t, memory.Versions("users/value"), []int{1})
+
+	// the same schema keeps its ID under every subject
+	id, err = client.Register("other-value", MustParseSchema(singleObjectWriterSchemaRaw))
+	assert(t, err, nil)
+	assert(t, id, 1)
+	id, err = client.Register("other-value", MustParseSchema(singleObjectReaderSchemaRaw))
+	assert(t, err, nil)
+	assert(t, id, 2)
+	assert(t, memory.Versions("other-value"), []int{1, 2})
+
+	fetched, err := client.GetSchema(1)
+	assert(t, err, nil)
+	assert(t, Fingerprint64(fetched), Fingerprint64(schema))
+	_, err = client.GetSchema(3)
+	assert(t, err, ErrSchemaNotFound)
+
+	_, err = client.Register("users-value", nil)
+	assert(t, err, ErrSchemaNotSet)
+}
+
+func TestSchemaRegistry_defaults(t *testing.T) {
+	// schemas which only differ in a default have the same fingerprint, but
+	// are different versions in a registry
+	a := MustParseSchema(`{"type": "record", "name": "R", "fields": [{"name": "f", "type": "int", "default": 1}]}`)
+	b := MustParseSchema(`{"type": "record", "name": "R", "fields": [{"name": "f", "type": "int", "default": 2}]}`)
+	assert(t, Fingerprint64(a), Fingerprint64(b))
+
+	memory := NewMemorySchemaRegistry()
+	server := httptest.NewServer(memory)
+	defer server.Close()
+	registries := map[string]SchemaRegistry{
+		"memory": NewMemorySchemaRegistry(),
+		"client": NewSchemaRegistryClient(server.URL, nil),
+		"cached": NewCachedSchemaRegistry(NewMemorySchemaRegistry()),
+	}
+	for name, registry := range registries {
+		idA, err := registry.Register("r-value", a)
+		assert(t, err, nil)
+		idB, err := registry.Register("r-value", b)
+		assert(t, err, nil)
+		if idA == idB {
+			t.Fatalf("%s: both schemas registered as %d", name, idA)
+		}
+		schema, err := registry.GetSchema(idB)
+		assert(t, err, nil)
+		assert(t, schema.(*RecordSchema).Fields[0].Default, int32(2))
+
+		// the same schema parsed again keeps its ID
+		id, err := registry.Register("r-value", MustParseSchema(`{"name": "R", "type": "record", "fields": [{"type": "int", "name": "f", "default": 2}]}`))
+		assert(t, err, nil)
+		assert(t, id, idB)
+	}
+	assert(t, memory.Versions("r-value"), []int{1, 2})
+
+	// the registry keeps schemas as they were sent
+	resp, err := http.Post(server.URL+"/subjects/r-value/versions", schemaRegistryContentType,
+		strings.NewReader(`{"schema": "{\"type\": \"string\", \"connect.name\": \"x\"}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(server.URL + "/schemas/ids/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body schemaRegistrySchema
+	assert(t, json.NewDecoder(resp.Body).Decode(&body), nil)
+	assert(t, body.Schema, `{"connect.name":"x","type":"string"}`)
+}
+
+func TestSchemaRegistryClient_errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/schemas/ids/1":
+			writeSchemaRegistryError(w, http.StatusUnauthorized, 40101, "Unauthorized")
+		case "/schemas/ids/2":
+			writeSchemaRegistryResponse(w, http.StatusOK, schemaRegistrySchema{Schema: `syntax = "proto3";`, SchemaType: "PROTOBUF"})
+		case "/schemas/ids/3":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("Bad gateway\n"))
+		default:
+			writeSchemaRegistryResponse(w, http.StatusOK, schemaRegistrySchema{Schema: `{"type": "nonsense"}`})
+		}
+	}))
+	defer server.Close()
+	client := NewSchemaRegistryClient(server.URL, nil)
+
+	_, err := client.GetSchema(1)
+	assert(t, err, &SchemaRegistryError{StatusCode: 401, ErrorCode: 40101, Message: "Unauthorized"})
+	_, err = client.GetSchema(3)
+	assert(t, err, &SchemaRegistryError{StatusCode: 502, Message: "Bad gateway"})
+	for _, id := range []int{2, 4} {
+		if _, err := client.GetSchema(id); err == nil || !strings.HasPrefix(err.Error(), "SchemaRegistryClient:") {
+			t.Fatalf("Expected an error fetching schema %d, actual %v", id, err)
+		}
+	}
+
+	// invalid schemas are rejected by the fake registry
+	memoryServer := httptest.NewServer(NewMemorySchemaRegistry())
+	defer memoryServer.Close()
+	resp, err := http.Post(memoryServer.URL+"/subjects/users-value/versions", schemaRegistryContentType,
+		strings.NewReader(`{"schema": "{\"type\": \"nonsense\"}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert(t, resp.StatusCode, http.StatusUnprocessableEntity)
+}
+
+type countingSchemaRegistry struct {
+	SchemaRegistry
+	gets, registrations int32
+}
+
+func (r *countingSchemaRegistry) GetSchema(id int) (Schema, error) {
+	atomic.AddInt32(&r.gets, 1)
+	return r.SchemaRegistry.GetSchema(id)
+}
+
+func (r *countingSchemaRegistry) Register(subject string, schema Schema) (int, error) {
+	atomic.AddInt32(&r.registrations, 1)
+	return r.SchemaRegistry.Register(subject, schema)
+}
+
+func TestCachedSchemaRegistry(t *testing.T) {
+	counting := &countingSchemaRegistry{SchemaRegistry: NewMemorySchemaRegistry()}
+	registry := NewCachedSchemaRegistry(counting)
+	schema := MustParseSchema(singleObjectWriterSchemaRaw)
+
+	for i := 0; i < 3; i++ {
+		id, err := registry.Register("users-value", schema)
+		assert(t, err, nil)
+		assert(t, id, 1)
+	}
+	assert(t, counting.registrations, int32(1))
+	id, err := registry.Register("other-value", schema)
+	assert(t, err, nil)
+	assert(t, id, 1)
+	assert(t, counting.registrations, int32(2))
+
+	// registered schemas are cached by ID too
+	actual, err := registry.GetSchema(1)
+	assert(t, err, nil)
+	assert(t, actual, schema)
+	assert(t, counting.gets, int32(0))
+
+	// errors aren't cached
+	for i := 0; i < 2; i++ {
+		_, err = registry.GetSchema(2)
+		assert(t, err, ErrSchemaNotFound)
+	}
+	assert(t, counting.gets, int32(2))
+	counting.Register("users-value", MustParseSchema(singleObjectReaderSchemaRaw))
+	for i := 0; i < 2; i++ {
+		_, err = registry.GetSchema(2)
+		assert(t, err, nil)
+	}
+	assert(t, counting.gets, int32(3))
+}
@@ -0,0 +1,173 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// The two bytes every single-object encoded message starts with.
+var singleObjectMarker = []byte{0xC3, 0x01}
+
+// Size of the header of a single-object encoded message: the marker
+// followed by the CRC-64-AVRO fingerprint of the writer schema.
+const singleObjectHeaderSize = 10
+
+// SchemaStore looks up the writer schemas of single-object encoded messages
+// by their CRC-64-AVRO fingerprint. Implementations must be safe for
+// concurrent use.
+type SchemaStore interface {
+	// FindByFingerprint returns the schema of the given fingerprint or
+	// ErrSchemaNotFound if it doesn't know it.
+	FindByFingerprint(fingerprint uint64) (Schema, error)
+}
+
+// MemorySchemaStore is a SchemaStore holding the schemas added to it.
+type MemorySchemaStore struct {
+	mu      sync.RWMutex
+	schemas map[uint64]Schema
+}
+
+// NewMemorySchemaStore creates a MemorySchemaStore holding the given schemas.
+func NewMemorySchemaStore(schemas ...Schema) (*MemorySchemaStore, error) {
+	store := &MemorySchemaStore{schemas: make(map[uint64]Schema)}
+	for _, schema := range schemas {
+		if _, err := store.Add(schema); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Add adds a schema to the store and returns its fingerprint.
+func (s *MemorySchemaStore) Add(schema Schema) (uint64, error) {
+	if schema == nil {
+		return 0, ErrSchemaNotSet
+	}
+	fingerprint := Fingerprint64(schema)
+	s.mu.Lock()
+	s.schemas[fingerprint] = schema
+	s.mu.Unlock()
+	return fingerprint, nil
+}
+
+// FindByFingerprint returns the schema of the given fingerprint.
+func (s *MemorySchemaStore) FindByFingerprint(fingerprint uint64) (Schema, error) {
+	s.mu.RLock()
+	schema, ok := s.schemas[fingerprint]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrSchemaNotFound
+	}
+	return schema, nil
+}
+
+// SingleObjectEncoder encodes datums as single-object encoded messages of
+// the specification, for exchanging individual datums e.g. over a queue:
+// the 0xC3 0x01 marker, the CRC-64-AVRO fingerprint of the writer schema in
+// little-endian order and the binary encoding of the datum. It's safe for
+// concurrent use if its DatumWriter is.
+type SingleObjectEncoder struct {
+	header      [singleObjectHeaderSize]byte
+	datumWriter DatumWriter
+}
+
+// NewSingleObjectEncoder creates a SingleObjectEncoder writing datums of the
+// given schema with datumWriter, e.g. NewDatumWriter(schema).
+func NewSingleObjectEncoder(schema Schema, datumWriter DatumWriter) (*SingleObjectEncoder, error) {
+	if schema == nil {
+		return nil, ErrSchemaNotSet
+	}
+	e := &SingleObjectEncoder{datumWriter: datumWriter}
+	copy(e.header[:], singleObjectMarker)
+	binary.LittleEndian.PutUint64(e.header[len(singleObjectMarker):], Fingerprint64(schema))
+	return e, nil
+}
+
+// Encode returns the message of a single datum.
+func (e *SingleObjectEncoder) Encode(v interface{}) ([]byte, error) {
+	return e.AppendEncode(nil, v)
+}
+
+// AppendEncode appends the message of a single datum to buf and returns the
+// extended slice.
+func (e *SingleObjectEncoder) AppendEncode(buf []byte, v interface{}) ([]byte, error) {
+	enc := NewAppendEncoder(append(buf, e.header[:]...))
+	if err := e.datumWriter.Write(v, enc); err != nil {
+		return buf, err
+	}
+	return enc.Bytes(), nil
+}
+
+// SingleObjectFingerprint returns the fingerprint of the writer schema of a
+// single-object encoded message.
+func SingleObjectFingerprint(message []byte) (uint64, error) {
+	if len(message) < singleObjectHeaderSize || !bytes.HasPrefix(message, singleObjectMarker) {
+		return 0, ErrNotSingleObject
+	}
+	return binary.LittleEndian.Uint64(message[len(singleObjectMarker):]), nil
+}
+
+// SingleObjectDecoder decodes single-object encoded messages, see
+// SingleObjectEncoder. The writer schema of every message is looked up in a
+// SchemaStore by its fingerprint and resolved to the reader schema with a
+// DatumProjector, which is built only once per writer schema. It's safe for
+// concurrent use.
+type SingleObjectDecoder struct {
+	readerSchema Schema
+	store        SchemaStore
+
+	mu      sync.RWMutex
+	readers map[uint64]DatumReader
+}
+
+// NewSingleObjectDecoder creates a SingleObjectDecoder which reads messages
+// as readerSchema. If readerSchema is nil, every message is read as its
+// writer schema.
+func NewSingleObjectDecoder(readerSchema Schema, store SchemaStore) *SingleObjectDecoder {
+	return &SingleObjectDecoder{
+		readerSchema: readerSchema,
+		store:        store,
+		readers:      make(map[uint64]DatumReader),
+	}
+}
+
+// Decode reads the datum of a message into v, a pointer as accepted by DatumReader.Read.
+func (d *SingleObjectDecoder) Decode(message []byte, v interface{}) error {
+	fingerprint, err := SingleObjectFingerprint(message)
+	if err != nil {
+		return err
+	}
+	reader, err := d.reader(fingerprint)
+	if err != nil {
+		return err
+	}
+	return reader.Read(v, NewBinaryDecoder(message[singleObjectHeaderSize:]))
+}
+
+// reader returns the DatumReader of a writer schema, building it on first use.
+func (d *SingleObjectDecoder) reader(fingerprint uint64) (DatumReader, error) {
+	d.mu.RLock()
+	reader, ok := d.readers[fingerprint]
+	d.mu.RUnlock()
+	if ok {
+		return reader, nil
+	}
+
+	schema, err := d.store.FindByFingerprint(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if d.readerSchema != nil {
+		if reader, err = NewDatumProjector(d.readerSchema, schema); err != nil {
+			return nil, fmt.Errorf("SingleObjectDecoder: Cannot resolve writer schema to reader schema: %s", err.Error())
+		}
+	} else {
+		reader = NewDatumReader(schema)
+	}
+	d.mu.Lock()
+	d.readers[fingerprint] = reader
+	d.mu.Unlock()
+	return reader, nil
+}
@@ -0,0 +1,109 @@
+package avro
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+const singleObjectWriterSchemaRaw = `{"type": "record", "name": "User", "fields": [
+	{"name": "name", "type": "string"},
+	{"name": "age", "type": "int"}
+]}`
+
+const singleObjectReaderSchemaRaw = `{"type": "record", "name": "User", "fields": [
+	{"name": "name", "type": "string"},
+	{"name": "email", "type": "string", "default": "unknown"}
+]}`
+
+type singleObjectUser struct {
+	Name  string `avro:"name"`
+	Age   int32  `avro:"age"`
+	Email string `avro:"email"`
+}
+
+func TestSingleObjectEncoding(t *testing.T) {
+	writerSchema := MustParseSchema(singleObjectWriterSchemaRaw)
+	readerSchema := MustParseSchema(singleObjectReaderSchemaRaw)
+	encoder, err := NewSingleObjectEncoder(writerSchema, NewDatumWriter(writerSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := encoder.Encode(&singleObjectUser{Name: "Ann", Age: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewMemorySchemaStore(readerSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := store.Add(writerSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, message[:2], []byte{0xC3, 0x01})
+	assert(t, binary.LittleEndian.Uint64(message[2:10]), fingerprint)
+	actual, err := SingleObjectFingerprint(message)
+	assert(t, err, nil)
+	assert(t, actual, fingerprint)
+
+	// resolved to the reader schema
+	decoder := NewSingleObjectDecoder(readerSchema, store)
+	for i := 0; i < 2; i++ {
+		var user singleObjectUser
+		assert(t, decoder.Decode(message, &user), nil)
+		assert(t, user, singleObjectUser{Name: "Ann", Email: "unknown"})
+	}
+	var record *GenericRecord
+	assert(t, decoder.Decode(message, &record), nil)
+	assert(t, record.Get("name"), "Ann")
+	assert(t, record.Get("email"), "unknown")
+
+	// read as the writer schema
+	var user singleObjectUser
+	assert(t, NewSingleObjectDecoder(nil, store).Decode(message, &user), nil)
+	assert(t, user, singleObjectUser{Name: "Ann", Age: 42})
+
+	// messages are appended to a buffer
+	buf, err := encoder.AppendEncode([]byte("prefix"), &singleObjectUser{Name: "Ann", Age: 42})
+	assert(t, err, nil)
+	assert(t, buf, append([]byte("prefix"), message...))
+}
+
+func TestSingleObjectEncoding_interoperability(t *testing.T) {
+	// the header carries the fingerprint of the specification, so messages
+	// are exchangeable with the Java and Python implementations
+	schema := MustParseSchema(`"int"`)
+	encoder, err := NewSingleObjectEncoder(schema, NewDatumWriter(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := encoder.Encode(int32(42))
+	assert(t, err, nil)
+	assert(t, message, []byte{0xC3, 0x01, 0x8F, 0x5C, 0x39, 0x3F, 0x1A, 0xD5, 0x75, 0x72, 0x54})
+}
+
+func TestSingleObjectDecoder_errors(t *testing.T) {
+	schema := MustParseSchema(singleObjectWriterSchemaRaw)
+	encoder, err := NewSingleObjectEncoder(schema, NewDatumWriter(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := encoder.Encode(&singleObjectUser{Name: "Ann", Age: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewMemorySchemaStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewSingleObjectDecoder(schema, store)
+
+	var user singleObjectUser
+	assert(t, decoder.Decode(message, &user), ErrSchemaNotFound)
+	assert(t, decoder.Decode(message[:9], &user), ErrNotSingleObject)
+	assert(t, decoder.Decode(append([]byte{0xC3, 0x02}, message[2:]...), &user), ErrNotSingleObject)
+
+	store.Add(schema)
+	assert(t, decoder.Decode(message[:len(message)-1], &user), ErrUnexpectedEOF)
+}